@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"path"
+	"strings"
+
+	"elano.fr/src/backend/auth"
+	"github.com/gofiber/fiber/v2"
+)
+
+// routeRoles are the requests that need more than the default role, first
+// match first. A * in a pattern matches one path segment. Other reads need
+// auth.Viewer and other changes auth.Programmer.
+var routeRoles = []struct {
+	method  string
+	pattern string
+	role    auth.Role
+}{
+	{"*", "/api/auth/tokens", auth.Admin},
+	{"*", "/api/auth/tokens/*", auth.Admin},
+	{fiber.MethodPost, "/api/projects/import", auth.Admin},
+	{fiber.MethodPost, "/api/backups/*/restore", auth.Admin},
+	{fiber.MethodPost, "/api/workspace/projects/*/switch", auth.Admin},
+	{fiber.MethodPost, "/api/workspace/projects/*/archive", auth.Admin},
+	{fiber.MethodPost, "/api/workspace/projects/*/restore", auth.Admin},
+}
+
+// requiredRole returns the role needed for a request to the API or the
+// WebSocket. WebSocket messages are checked one by one by the ws package.
+// Fiber matches routes regardless of case and of a trailing slash, so the
+// path is matched the same way.
+func requiredRole(method, p string) auth.Role {
+	p = strings.ToLower(strings.TrimRight(p, "/"))
+	for _, r := range routeRoles {
+		if r.method != "*" && r.method != method {
+			continue
+		}
+		if ok, _ := path.Match(r.pattern, p); ok {
+			return r.role
+		}
+	}
+	if method == fiber.MethodGet || method == fiber.MethodHead {
+		return auth.Viewer
+	}
+	return auth.Programmer
+}
+
+// bearerToken returns the token of a request, from its Authorization header
+// or, for WebSocket connections and downloads opened by the browser, from its
+// token query parameter.
+func bearerToken(c *fiber.Ctx) string {
+	if header := c.Get(fiber.HeaderAuthorization); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	return c.Query("token")
+}
+
+// Identity returns who sent a request, as set by the authentication
+// middleware.
+func Identity(c *fiber.Ctx) *auth.Identity {
+	if id, ok := c.Locals("identity").(*auth.Identity); ok {
+		return id
+	}
+	return nil
+}
+
+// bootstrapping reports whether a request creates the first token from the
+// machine running the server, which is the only change allowed before there
+// is a token. The address of the connection is used rather than c.IP, which
+// may come from a forwarded header.
+func bootstrapping(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPost &&
+		strings.ToLower(strings.TrimRight(c.Path(), "/")) == "/api/auth/tokens" &&
+		c.Context().RemoteIP().IsLoopback()
+}
+
+// RegisterAuthRoutes installs the authentication middleware in front of the
+// API and the WebSocket, so it must be called before any route is registered.
+// When there are no tokens, every client is auth.Anonymous and can only read,
+// except that the first token can be created from the server itself.
+func RegisterAuthRoutes(app *fiber.App, tokens *auth.Tokens) {
+	authenticate := func(c *fiber.Ctx) error {
+		if !tokens.Enabled() {
+			required := requiredRole(c.Method(), c.Path())
+			if !auth.Anonymous.Role.Allows(required) && !bootstrapping(c) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error":   "Authentication not set up",
+					"details": "create the first admin token with POST /api/auth/tokens from the server itself, or set ADMIN_TOKEN",
+				})
+			}
+			c.Locals("identity", auth.Anonymous)
+			return c.Next()
+		}
+		id, ok := tokens.Authenticate(bearerToken(c))
+		if !ok {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="luma"`)
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "Authentication required",
+			})
+		}
+		required := requiredRole(c.Method(), c.Path())
+		if !id.Role.Allows(required) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":    "Insufficient role",
+				"role":     id.Role,
+				"required": required,
+			})
+		}
+		c.Locals("identity", id)
+		return c.Next()
+	}
+	app.Use("/api", authenticate)
+	app.Use("/ws", authenticate)
+
+	r := app.Group("/api/auth")
+
+	r.Get("/me", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"enabled":  tokens.Enabled(),
+			"identity": Identity(c),
+		})
+	})
+
+	r.Get("/tokens", func(c *fiber.Ctx) error {
+		return c.JSON(tokens.List())
+	})
+
+	// Creating a token is the only time its secret is returned.
+	r.Post("/tokens", func(c *fiber.Ctx) error {
+		var req struct {
+			Name string    `json:"name"`
+			Role auth.Role `json:"role"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+		token, secret, err := tokens.Create(req.Name, req.Role)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Failed to create token",
+				"details": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"token":  token,
+			"secret": secret,
+		})
+	})
+
+	r.Delete("/tokens/:id", func(c *fiber.Ctx) error {
+		if err := tokens.Revoke(c.Params("id")); err != nil {
+			status := fiber.StatusInternalServerError
+			switch {
+			case errors.Is(err, auth.ErrTokenNotFound):
+				status = fiber.StatusNotFound
+			case errors.Is(err, auth.ErrLastAdmin):
+				status = fiber.StatusConflict
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error":   "Failed to revoke token",
+				"details": err.Error(),
+			})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+
+	"elano.fr/src/backend/storage"
+	"elano.fr/src/backend/ws"
+	"github.com/gofiber/fiber/v2"
+)
+
+func RegisterBackupRoutes(app *fiber.App, store storage.ProjectBackups, enableDMX bool) {
+	r := app.Group("/api/backups", revisionCheck(store))
+
+	r.Get("/", func(c *fiber.Ctx) error {
+		backups, err := store.Backups()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Failed to list backups",
+				"details": err.Error(),
+			})
+		}
+		policy := store.BackupPolicy()
+		return c.JSON(fiber.Map{
+			"backups": backups,
+			"policy": fiber.Map{
+				"interval":    policy.Interval.String(),
+				"every_saves": policy.EverySaves,
+				"keep":        policy.Keep,
+				"max_age":     policy.MaxAge.String(),
+			},
+		})
+	})
+
+	r.Post("/", func(c *fiber.Ctx) error {
+		if err := store.Backup(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Failed to create backup",
+				"details": err.Error(),
+			})
+		}
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	r.Get("/:name", func(c *fiber.Ctx) error {
+		path, err := store.BackupFile(c.Params("name"))
+		if err != nil {
+			return backupError(c, "Failed to read backup", err)
+		}
+		return c.Download(path, c.Params("name"))
+	})
+
+	// Diff lists what restoring the backup would change in the current
+	// project, with the before and after state of every entity.
+	r.Get("/:name/diff", func(c *fiber.Ctx) error {
+		backup, err := store.LoadBackup(c.Params("name"))
+		if err != nil {
+			return backupError(c, "Failed to read backup", err)
+		}
+		current := store.Get()
+		if current != nil {
+			backup.ID = current.ID
+		}
+		changes := storage.DiffProjects(current, backup)
+		if changes == nil {
+			changes = []storage.Change{}
+		}
+		return c.JSON(fiber.Map{
+			"backup":  c.Params("name"),
+			"changes": changes,
+		})
+	})
+
+	// Restore backs up the current project, then replaces it with the
+	// backup. The restore is a regular save, so it can be undone.
+	r.Post("/:name/restore", func(c *fiber.Ctx) error {
+		backup, err := store.LoadBackup(c.Params("name"))
+		if err != nil {
+			return backupError(c, "Failed to read backup", err)
+		}
+
+		current := store.Get()
+		backup.Revision = 0
+		if current != nil {
+			if err := store.Backup(); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "Failed to back up the current project",
+					"details": err.Error(),
+				})
+			}
+			backup.ID = current.ID
+			backup.Revision = current.Revision
+		}
+		changes := storage.DiffProjects(current, backup)
+
+		if err := saveProject(c, store, backup); err != nil {
+			return saveError(c, store, "Failed to restore backup", err)
+		}
+
+		ws.ProjectSwitched()
+
+		if enableDMX {
+			if err := ws.InitializeDMXController(backup.USBInterface); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "Failed to initialize DMX controller",
+					"details": err.Error(),
+				})
+			}
+		}
+
+		return c.JSON(fiber.Map{
+			"project": backup,
+			"changes": changeSummaries(changes),
+		})
+	})
+}
+
+func backupError(c *fiber.Ctx, message string, err error) error {
+	status := fiber.StatusInternalServerError
+	if errors.Is(err, storage.ErrBackupNotFound) {
+		status = fiber.StatusNotFound
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+
+	"elano.fr/src/backend/chase"
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/storage"
+	"elano.fr/src/backend/ws"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type chaseRequest struct {
+	Name       string        `json:"name"`
+	FixtureIDs []string      `json:"fixture_ids"`
+	Look       chase.Look    `json:"look"`
+	Pattern    chase.Pattern `json:"pattern"`
+	StepMS     int           `json:"step_ms"`
+	FadeMS     int           `json:"fade_ms"`
+	Seed       int64         `json:"seed"`
+	Persist    bool          `json:"persist"`
+	Run        bool          `json:"run"`
+	Loop       bool          `json:"loop"`
+}
+
+func RegisterChaseRoutes(app *fiber.App, store storage.ProjectStore) {
+	r := app.Group("/api/chases")
+
+	r.Post("/", func(c *fiber.Ctx) error {
+		var req chaseRequest
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		if req.Persist && req.Name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Chase name is required",
+			})
+		}
+		if req.StepMS <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Step time must be positive",
+			})
+		}
+		if req.FadeMS < 0 || req.FadeMS > req.StepMS {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Fade time must be between 0 and the step time",
+			})
+		}
+		if !req.Persist && !req.Run {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Chase must be persisted, run, or both",
+			})
+		}
+		// Running the chase drives the output, which only the owner of the
+		// control lock may do while a console holds it.
+		if req.Run {
+			if err := ws.ControlLocked(); err != nil {
+				return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+					"error":   "Control is locked",
+					"details": err.Error(),
+				})
+			}
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		fixtures := make([]models.Fixture, 0, len(req.FixtureIDs))
+		for _, id := range req.FixtureIDs {
+			found := false
+			for _, f := range project.Fixtures {
+				if f.ID == id {
+					fixtures = append(fixtures, f)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error": "Fixture not found",
+					"id":    id,
+				})
+			}
+		}
+
+		steps, err := chase.Generate(fixtures, req.Look, req.Pattern, req.Seed)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Failed to generate chase",
+				"details": err.Error(),
+			})
+		}
+
+		if !req.Persist {
+			showID := "chase-" + uuid.NewString()
+			if err := ws.RunTransientShow(showID, steps, req.StepMS, req.FadeMS, req.Loop); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "Failed to run chase",
+					"details": err.Error(),
+				})
+			}
+			return c.JSON(fiber.Map{
+				"show_id": showID,
+				"steps":   len(steps),
+			})
+		}
+
+		show := models.Show{ID: uuid.NewString(), Name: req.Name}
+		presets := make([]models.Preset, 0, len(steps))
+		for i, channels := range steps {
+			preset := models.Preset{
+				ID:          uuid.NewString(),
+				Name:        fmt.Sprintf("%s - step %d", req.Name, i+1),
+				Description: fmt.Sprintf("Generated %s chase step", req.Pattern),
+				Channels:    make([]models.ChannelValue, 0, len(channels)),
+			}
+			for addr, v := range channels {
+				preset.Channels = append(preset.Channels, models.ChannelValue{DMXAddress: addr, Value: v})
+			}
+			sort.Slice(preset.Channels, func(a, b int) bool {
+				return preset.Channels[a].DMXAddress < preset.Channels[b].DMXAddress
+			})
+			presets = append(presets, preset)
+			show.Steps = append(show.Steps, models.ShowStep{PresetID: preset.ID, Duration: req.StepMS, FadeMS: req.FadeMS})
+		}
+
+		project.Presets = append(project.Presets, presets...)
+		project.Shows = append(project.Shows, show)
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to save chase", err)
+		}
+
+		if req.Run {
+			if err := ws.RunShow(show.ID, req.Loop); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "Chase saved but failed to run",
+					"details": err.Error(),
+					"show":    show,
+				})
+			}
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"show":    show,
+			"presets": presets,
+		})
+	})
+}
@@ -8,7 +8,7 @@ import (
 )
 
 func RegisterFixtureRoutes(app *fiber.App, store storage.ProjectStore) {
-	r := app.Group("/api/fixtures")
+	r := app.Group("/api/fixtures", revisionCheck(store))
 
 	r.Get("/", func(c *fiber.Ctx) error {
 		project := store.Get()
@@ -72,25 +72,11 @@ func RegisterFixtureRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
-		for i, ch := range fixture.Channels {
-			if ch.Name == "" {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error":         "Channel name is required",
-					"channel_index": i,
-				})
-			}
-			if ch.Min < 0 || ch.Max > 255 || ch.Min > ch.Max {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error":   "Invalid channel range (must be 0-255, min <= max)",
-					"channel": ch.Name,
-				})
-			}
-			if ch.ChannelAddress < 1 || ch.ChannelAddress > 512 {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error":   "Channel address must be between 1 and 512",
-					"channel": ch.Name,
-				})
-			}
+		if err := storage.ValidateFixtureChannels(fixture.Channels); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid fixture channel",
+				"details": err.Error(),
+			})
 		}
 
 		fixture.ID = uuid.New().String()
@@ -104,11 +90,8 @@ func RegisterFixtureRoutes(app *fiber.App, store storage.ProjectStore) {
 
 		project.Fixtures = append(project.Fixtures, fixture)
 
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to save fixture",
-				"details": err.Error(),
-			})
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to save fixture", err)
 		}
 
 		return c.Status(fiber.StatusCreated).JSON(fixture)
@@ -145,6 +128,12 @@ func RegisterFixtureRoutes(app *fiber.App, store storage.ProjectStore) {
 				"error": "At least one channel is required",
 			})
 		}
+		if err := storage.ValidateFixtureChannels(update.Channels); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid fixture channel",
+				"details": err.Error(),
+			})
+		}
 
 		project := store.Get()
 		if project == nil {
@@ -170,58 +159,14 @@ func RegisterFixtureRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to update fixture",
-				"details": err.Error(),
-			})
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to update fixture", err)
 		}
 
 		return c.JSON(update)
 	})
 
 	r.Delete("/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		if id == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Fixture ID is required",
-			})
-		}
-
-		project := store.Get()
-		if project == nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to load project",
-			})
-		}
-
-		fixtures := make([]models.Fixture, 0, len(project.Fixtures))
-		found := false
-
-		for _, f := range project.Fixtures {
-			if f.ID != id {
-				fixtures = append(fixtures, f)
-			} else {
-				found = true
-			}
-		}
-
-		if !found {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Fixture not found",
-				"id":    id,
-			})
-		}
-
-		project.Fixtures = fixtures
-
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to delete fixture",
-				"details": err.Error(),
-			})
-		}
-
-		return c.SendStatus(fiber.StatusNoContent)
+		return deleteEntity(c, store, "fixture", "Fixture")
 	})
 }
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"elano.fr/src/backend/storage"
+	"elano.fr/src/backend/ws"
+	"github.com/gofiber/fiber/v2"
+)
+
+type historyChange struct {
+	Entity string `json:"entity"`
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Op     string `json:"op"`
+}
+
+type historyItem struct {
+	ID          string          `json:"id"`
+	Time        time.Time       `json:"time"`
+	Description string          `json:"description"`
+	Changes     []historyChange `json:"changes"`
+}
+
+func RegisterHistoryRoutes(app *fiber.App, history storage.ProjectHistory) {
+	app.Get("/api/history", func(c *fiber.Ctx) error {
+		undo, redo := history.History()
+		return c.JSON(fiber.Map{
+			"undo": historyItems(undo),
+			"redo": historyItems(redo),
+		})
+	})
+
+	app.Post("/api/undo", revisionCheck(history), func(c *fiber.Ctx) error {
+		entry, err := history.Undo()
+		return historyResponse(c, history, "undo", entry, err)
+	})
+
+	app.Post("/api/redo", revisionCheck(history), func(c *fiber.Ctx) error {
+		entry, err := history.Redo()
+		return historyResponse(c, history, "redo", entry, err)
+	})
+}
+
+func historyResponse(c *fiber.Ctx, history storage.ProjectHistory, action string, entry *storage.HistoryEntry, err error) error {
+	if errors.Is(err, storage.ErrNothingToUndo) || errors.Is(err, storage.ErrNothingToRedo) || errors.Is(err, storage.ErrHistoryConflict) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to " + action,
+			"details": err.Error(),
+		})
+	}
+	if project := history.Get(); project != nil {
+		c.Set(fiber.HeaderETag, etag(project))
+	}
+	item := toHistoryItem(*entry)
+	ws.NotifyHistory(action, item)
+	return c.JSON(item)
+}
+
+func historyItems(entries []storage.HistoryEntry) []historyItem {
+	items := make([]historyItem, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, toHistoryItem(e))
+	}
+	return items
+}
+
+func toHistoryItem(e storage.HistoryEntry) historyItem {
+	return historyItem{ID: e.ID, Time: e.Time, Description: e.Description, Changes: changeSummaries(e.Changes)}
+}
+
+func changeSummaries(changes []storage.Change) []historyChange {
+	out := make([]historyChange, len(changes))
+	for i, c := range changes {
+		out[i] = historyChange{Entity: c.Entity, ID: c.ID, Name: c.Name, Op: c.Op}
+	}
+	return out
+}
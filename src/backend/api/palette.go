@@ -0,0 +1,132 @@
+package api
+
+import (
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/palette"
+	"elano.fr/src/backend/storage"
+	"elano.fr/src/backend/ws"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func RegisterPaletteRoutes(app *fiber.App, store storage.ProjectStore) {
+	api := app.Group("/api/palettes", revisionCheck(store))
+
+	api.Get("/", func(c *fiber.Ctx) error {
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+		return c.JSON(project.Palettes)
+	})
+
+	api.Get("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		for _, pal := range project.Palettes {
+			if pal.ID == id {
+				return c.JSON(pal)
+			}
+		}
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Palette not found",
+			"id":    id,
+		})
+	})
+
+	api.Post("/", func(c *fiber.Ctx) error {
+		var input models.Palette
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		if err := palette.Validate(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid palette",
+				"details": err.Error(),
+			})
+		}
+
+		input.ID = uuid.NewString()
+		project.Palettes = append(project.Palettes, input)
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to save palette", err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(input)
+	})
+
+	api.Put("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		var input models.Palette
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		if err := palette.Validate(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid palette",
+				"details": err.Error(),
+			})
+		}
+
+		found := false
+		for i, pal := range project.Palettes {
+			if pal.ID == id {
+				input.ID = id
+				project.Palettes[i] = input
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Palette not found",
+				"id":    id,
+			})
+		}
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to update palette", err)
+		}
+
+		ws.PaletteChanged(id)
+
+		return c.JSON(input)
+	})
+
+	api.Delete("/:id", func(c *fiber.Ctx) error {
+		return deleteEntity(c, store, "palette", "Palette")
+	})
+}
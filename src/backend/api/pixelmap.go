@@ -0,0 +1,161 @@
+package api
+
+import (
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/pixelmap"
+	"elano.fr/src/backend/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func RegisterPixelMapRoutes(app *fiber.App, store storage.ProjectStore) {
+	api := app.Group("/api/pixelmaps", revisionCheck(store))
+
+	api.Get("/", func(c *fiber.Ctx) error {
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+		return c.JSON(project.PixelMaps)
+	})
+
+	api.Get("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		for _, pm := range project.PixelMaps {
+			if pm.ID == id {
+				return c.JSON(pm)
+			}
+		}
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Pixel map not found",
+			"id":    id,
+		})
+	})
+
+	api.Post("/", func(c *fiber.Ctx) error {
+		var input models.PixelMap
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		if _, err := pixelmap.Resolve(input, project.Fixtures); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid pixel map",
+				"details": err.Error(),
+			})
+		}
+
+		input.ID = uuid.NewString()
+		project.PixelMaps = append(project.PixelMaps, input)
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to save pixel map", err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(input)
+	})
+
+	api.Put("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		var input models.PixelMap
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		if _, err := pixelmap.Resolve(input, project.Fixtures); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid pixel map",
+				"details": err.Error(),
+			})
+		}
+
+		found := false
+		for i, pm := range project.PixelMaps {
+			if pm.ID == id {
+				input.ID = id
+				project.PixelMaps[i] = input
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Pixel map not found",
+				"id":    id,
+			})
+		}
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to update pixel map", err)
+		}
+
+		return c.JSON(input)
+	})
+
+	api.Delete("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		pixelMaps := make([]models.PixelMap, 0, len(project.PixelMaps))
+		found := false
+
+		for _, pm := range project.PixelMaps {
+			if pm.ID != id {
+				pixelMaps = append(pixelMaps, pm)
+			} else {
+				found = true
+			}
+		}
+
+		if !found {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Pixel map not found",
+				"id":    id,
+			})
+		}
+
+		project.PixelMaps = pixelMaps
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to delete pixel map", err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
@@ -2,13 +2,15 @@ package api
 
 import (
 	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/palette"
 	"elano.fr/src/backend/storage"
+	"elano.fr/src/backend/ws"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
 func RegisterPresetRoutes(app *fiber.App, store storage.ProjectStore) {
-	api := app.Group("/api/presets")
+	api := app.Group("/api/presets", revisionCheck(store))
 
 	api.Get("/", func(c *fiber.Ctx) error {
 		project := store.Get()
@@ -47,6 +49,41 @@ func RegisterPresetRoutes(app *fiber.App, store storage.ProjectStore) {
 		})
 	})
 
+	api.Post("/record", func(c *fiber.Ctx) error {
+		var input ws.RecordPresetPayload
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		preset, err := ws.RecordPreset(input)
+		if err != nil {
+			status := fiber.StatusInternalServerError
+			switch ws.ActionErrorType(err) {
+			case "invalid_payload":
+				status = fiber.StatusBadRequest
+			case "preset_not_found", "fixture_not_found":
+				status = fiber.StatusNotFound
+			case "dmx_error":
+				status = fiber.StatusServiceUnavailable
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"error":   "Failed to record preset",
+				"details": err.Error(),
+			})
+		}
+
+		if project := store.Get(); project != nil {
+			c.Set(fiber.HeaderETag, etag(project))
+		}
+		if input.PresetID == "" {
+			return c.Status(fiber.StatusCreated).JSON(preset)
+		}
+		return c.JSON(preset)
+	})
+
 	api.Post("/", func(c *fiber.Ctx) error {
 		var input models.Preset
 		if err := c.BodyParser(&input); err != nil {
@@ -62,9 +99,9 @@ func RegisterPresetRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
-		if len(input.Channels) == 0 {
+		if len(input.Channels) == 0 && len(input.Palettes) == 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "At least one channel value is required",
+				"error": "At least one channel value or palette is required",
 			})
 		}
 
@@ -95,15 +132,19 @@ func RegisterPresetRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
-		project.Presets = append(project.Presets, input)
-
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to save preset",
+		if err := palette.ValidateRefs(input, project); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid palette reference",
 				"details": err.Error(),
 			})
 		}
 
+		project.Presets = append(project.Presets, input)
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to save preset", err)
+		}
+
 		return c.Status(fiber.StatusCreated).JSON(input)
 	})
 
@@ -129,9 +170,9 @@ func RegisterPresetRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
-		if len(input.Channels) == 0 {
+		if len(input.Channels) == 0 && len(input.Palettes) == 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "At least one channel value is required",
+				"error": "At least one channel value or palette is required",
 			})
 		}
 
@@ -159,6 +200,13 @@ func RegisterPresetRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
+		if err := palette.ValidateRefs(input, project); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid palette reference",
+				"details": err.Error(),
+			})
+		}
+
 		found := false
 		for i, p := range project.Presets {
 			if p.ID == id {
@@ -176,58 +224,14 @@ func RegisterPresetRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to update preset",
-				"details": err.Error(),
-			})
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to update preset", err)
 		}
 
 		return c.JSON(input)
 	})
 
 	api.Delete("/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		if id == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Preset ID is required",
-			})
-		}
-
-		project := store.Get()
-		if project == nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to load project",
-			})
-		}
-
-		presets := make([]models.Preset, 0, len(project.Presets))
-		found := false
-
-		for _, p := range project.Presets {
-			if p.ID != id {
-				presets = append(presets, p)
-			} else {
-				found = true
-			}
-		}
-
-		if !found {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Preset not found",
-				"id":    id,
-			})
-		}
-
-		project.Presets = presets
-
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to delete preset",
-				"details": err.Error(),
-			})
-		}
-
-		return c.SendStatus(fiber.StatusNoContent)
+		return deleteEntity(c, store, "preset", "Preset")
 	})
 }
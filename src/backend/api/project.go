@@ -1,16 +1,24 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
 	"elano.fr/src/backend/models"
 	"elano.fr/src/backend/storage"
 	"elano.fr/src/backend/ws"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 )
 
+// RegisterProjectRoutes serves the active project. Projects are created,
+// archived and switched through the workspace routes.
 func RegisterProjectRoutes(app *fiber.App, store storage.ProjectStore, enableDMX bool) {
-	r := app.Group("/api/projects")
+	r := app.Group("/api/projects", revisionCheck(store))
 
 	r.Get("/", func(c *fiber.Ctx) error {
 		project := store.Get()
@@ -22,7 +30,7 @@ func RegisterProjectRoutes(app *fiber.App, store storage.ProjectStore, enableDMX
 		return c.JSON(project)
 	})
 
-	r.Post("/", func(c *fiber.Ctx) error {
+	r.Put("/", func(c *fiber.Ctx) error {
 		var proj models.Project
 		if err := c.BodyParser(&proj); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -31,6 +39,12 @@ func RegisterProjectRoutes(app *fiber.App, store storage.ProjectStore, enableDMX
 			})
 		}
 
+		if proj.ID == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Project ID is required",
+			})
+		}
+
 		if proj.Name == "" {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": "Project name is required",
@@ -43,27 +57,39 @@ func RegisterProjectRoutes(app *fiber.App, store storage.ProjectStore, enableDMX
 			})
 		}
 
-		isNew := false
-		if proj.ID == "" {
-			proj.ID = uuid.New().String()
-			isNew = true
+		currentProject := store.Get()
+		if currentProject == nil || currentProject.ID != proj.ID {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Project not found or ID mismatch",
+				"id":    proj.ID,
+			})
 		}
 
 		if proj.Fixtures == nil {
-			proj.Fixtures = []models.Fixture{}
+			proj.Fixtures = currentProject.Fixtures
 		}
 		if proj.Presets == nil {
-			proj.Presets = []models.Preset{}
+			proj.Presets = currentProject.Presets
 		}
 		if proj.Shows == nil {
-			proj.Shows = []models.Show{}
+			proj.Shows = currentProject.Shows
+		}
+		if proj.Schedules == nil {
+			proj.Schedules = currentProject.Schedules
+		}
+		if proj.Location == nil {
+			proj.Location = currentProject.Location
+		}
+		if proj.PixelMaps == nil {
+			proj.PixelMaps = currentProject.PixelMaps
+		}
+		if proj.Palettes == nil {
+			proj.Palettes = currentProject.Palettes
 		}
+		proj.Revision = currentProject.Revision
 
-		if err := store.Save(&proj); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to save project",
-				"details": err.Error(),
-			})
+		if err := saveProject(c, store, &proj); err != nil {
+			return saveError(c, store, "Failed to update project", err)
 		}
 
 		if enableDMX {
@@ -75,67 +101,97 @@ func RegisterProjectRoutes(app *fiber.App, store storage.ProjectStore, enableDMX
 			}
 		}
 
-		status := fiber.StatusOK
-		if isNew {
-			status = fiber.StatusCreated
-		}
-		return c.Status(status).JSON(proj)
+		return c.JSON(proj)
 	})
 
-	r.Put("/", func(c *fiber.Ctx) error {
-		var proj models.Project
-		if err := c.BodyParser(&proj); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error":   "Invalid request body",
-				"details": err.Error(),
+	// Integrity lists the references of the project to entities that do
+	// not exist, left by hand edits or by older versions.
+	r.Get("/integrity", func(c *fiber.Ctx) error {
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "No project found",
 			})
 		}
+		dangling := storage.DanglingReferences(project)
+		if dangling == nil {
+			dangling = []storage.Reference{}
+		}
+		return c.JSON(fiber.Map{
+			"valid":    len(dangling) == 0,
+			"dangling": dangling,
+		})
+	})
 
-		if proj.ID == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Project ID is required",
+	r.Get("/export", func(c *fiber.Ctx) error {
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "No project found",
 			})
 		}
-
-		if proj.Name == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Project name is required",
+		project.SchemaVersion = storage.CurrentSchemaVersion
+		data, err := json.MarshalIndent(project, "", "  ")
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Failed to export project",
+				"details": err.Error(),
 			})
 		}
+		c.Attachment(exportFilename(project.Name))
+		c.Type("json")
+		return c.Send(append(data, '\n'))
+	})
 
-		if proj.USBInterface == "" {
+	// Import replaces the content of the current project with an uploaded
+	// JSON export. Entities get fresh IDs unless remap_ids=false, and
+	// dry_run=true only reports the changes the import would make.
+	r.Post("/import", func(c *fiber.Ctx) error {
+		body, err := uploadedFile(c)
+		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "USB interface is required",
+				"error":   "Invalid upload",
+				"details": err.Error(),
 			})
 		}
-
-		currentProject := store.Get()
-		if currentProject == nil || currentProject.ID != proj.ID {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Project not found or ID mismatch",
-				"id":    proj.ID,
+		imported, err := storage.DecodeProjectJSON(bytes.NewReader(body))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid project file",
+				"details": err.Error(),
 			})
 		}
 
-		if proj.Fixtures == nil {
-			proj.Fixtures = currentProject.Fixtures
+		var ids *storage.IDMap
+		if c.QueryBool("remap_ids", true) {
+			m := storage.RemapIDs(imported)
+			ids = &m
 		}
-		if proj.Presets == nil {
-			proj.Presets = currentProject.Presets
-		}
-		if proj.Shows == nil {
-			proj.Shows = currentProject.Shows
+
+		current := store.Get()
+		imported.Revision = 0
+		if current != nil {
+			imported.ID = current.ID
+			imported.Revision = current.Revision
 		}
+		changes := storage.DiffProjects(current, imported)
 
-		if err := store.Save(&proj); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to update project",
-				"details": err.Error(),
+		if c.QueryBool("dry_run") {
+			return c.JSON(fiber.Map{
+				"dry_run": true,
+				"changes": changeSummaries(changes),
+				"id_map":  ids,
 			})
 		}
 
+		if err := saveProject(c, store, imported); err != nil {
+			return saveError(c, store, "Failed to import project", err)
+		}
+
+		ws.ProjectSwitched()
+
 		if enableDMX {
-			if err := ws.InitializeDMXController(proj.USBInterface); err != nil {
+			if err := ws.InitializeDMXController(imported.USBInterface); err != nil {
 				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 					"error":   "Failed to initialize DMX controller",
 					"details": err.Error(),
@@ -143,24 +199,144 @@ func RegisterProjectRoutes(app *fiber.App, store storage.ProjectStore, enableDMX
 			}
 		}
 
-		return c.JSON(proj)
+		return c.JSON(fiber.Map{
+			"project": imported,
+			"changes": changeSummaries(changes),
+			"id_map":  ids,
+		})
 	})
 
-	r.Delete("/", func(c *fiber.Ctx) error {
-		project := store.Get()
-		if project == nil {
+	// Merge brings selected fixtures, presets and shows of another project
+	// file, YAML or JSON, into the current project under fresh IDs. The
+	// selection is given as comma-separated fixture_ids, preset_ids and
+	// show_ids form or query values. DMX address conflicts with existing
+	// fixtures are reported and block the merge unless force=true.
+	r.Post("/merge", func(c *fiber.Ctx) error {
+		body, err := uploadedFile(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid upload",
+				"details": err.Error(),
+			})
+		}
+		source, err := storage.DecodeProject(body)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid project file",
+				"details": err.Error(),
+			})
+		}
+
+		current := store.Get()
+		if current == nil {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "No project to delete",
+				"error": "No project found",
 			})
 		}
 
-		if err := store.Delete(); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to delete project",
+		selection := storage.MergeSelection{
+			FixtureIDs: formList(c, "fixture_ids"),
+			PresetIDs:  formList(c, "preset_ids"),
+			ShowIDs:    formList(c, "show_ids"),
+		}
+		result, err := storage.MergeProjects(current, source, selection)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid selection",
 				"details": err.Error(),
 			})
 		}
 
-		return c.SendStatus(fiber.StatusNoContent)
+		if c.QueryBool("dry_run") {
+			return c.JSON(fiber.Map{
+				"dry_run":   true,
+				"available": mergeAvailable(source),
+				"merge":     result,
+			})
+		}
+		if len(result.Conflicts) > 0 && !c.QueryBool("force") {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Imported fixtures use DMX addresses already in use",
+				"merge": result,
+			})
+		}
+
+		if err := saveProject(c, store, result.Project); err != nil {
+			return saveError(c, store, "Failed to merge project", err)
+		}
+		return c.JSON(fiber.Map{
+			"project": result.Project,
+			"merge":   result,
+		})
 	})
 }
+
+// uploadedFile returns the file of a multipart upload, or the raw request body
+// when the request is not multipart.
+func uploadedFile(c *fiber.Ctx) ([]byte, error) {
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		if len(c.Body()) == 0 {
+			return nil, fmt.Errorf("request body is empty")
+		}
+		return c.Body(), nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("missing file field: %w", err)
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func exportFilename(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, name)
+	if safe == "" {
+		safe = "project"
+	}
+	return safe + ".json"
+}
+
+func formList(c *fiber.Ctx, key string) []string {
+	var out []string
+	for _, v := range strings.Split(c.FormValue(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+type mergeEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// mergeAvailable lists what a project file offers for merging.
+func mergeAvailable(p *models.Project) fiber.Map {
+	fixtures := make([]mergeEntity, len(p.Fixtures))
+	for i, f := range p.Fixtures {
+		fixtures[i] = mergeEntity{ID: f.ID, Name: f.Name}
+	}
+	presets := make([]mergeEntity, len(p.Presets))
+	for i, pr := range p.Presets {
+		presets[i] = mergeEntity{ID: pr.ID, Name: pr.Name}
+	}
+	shows := make([]mergeEntity, len(p.Shows))
+	for i, s := range p.Shows {
+		shows[i] = mergeEntity{ID: s.ID, Name: s.Name}
+	}
+	return fiber.Map{
+		"fixtures": fixtures,
+		"presets":  presets,
+		"shows":    shows,
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"errors"
+	"strings"
+
+	"elano.fr/src/backend/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// deleteEntity removes a fixture, palette, preset or show. An entity still in
+// use is refused with its dependents unless the request asks to cascade
+// (?cascade=true) or to point the dependents at another entity
+// (?replace=<id>).
+func deleteEntity(c *fiber.Ctx, store storage.ProjectStore, kind, label string) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": label + " ID is required",
+		})
+	}
+
+	project := store.Get()
+	if project == nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to load project",
+		})
+	}
+
+	// The replacement ID ends up in the project, so it must not alias the
+	// request buffer Fiber reuses.
+	opts := storage.RemoveOptions{
+		Cascade:     c.QueryBool("cascade"),
+		ReplaceWith: strings.Clone(c.Query("replace")),
+	}
+	if opts.Cascade && opts.ReplaceWith != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "cascade and replace cannot be combined",
+		})
+	}
+
+	dependents, err := storage.RemoveEntity(project, kind, id, opts)
+	if errors.Is(err, storage.ErrInUse) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":      label + " is in use",
+			"id":         id,
+			"dependents": dependents,
+		})
+	}
+	if errors.Is(err, storage.ErrEntityNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   label + " not found",
+			"id":      id,
+			"details": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to delete " + kind,
+			"details": err.Error(),
+		})
+	}
+
+	if err := saveProject(c, store, project); err != nil {
+		return saveError(c, store, "Failed to delete "+kind, err)
+	}
+
+	if len(dependents) == 0 {
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+	return c.JSON(fiber.Map{
+		"id":         id,
+		"dependents": dependents,
+	})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+var errPreconditionRequired = errors.New("If-Match header is required")
+
+// etag identifies a project revision. It includes the project ID so a tag
+// taken from one project of the workspace never matches another.
+func etag(project *models.Project) string {
+	return `"` + project.ID + "." + strconv.FormatInt(project.Revision, 10) + `"`
+}
+
+// ifMatch checks the If-Match header against the revision of project. "*"
+// matches whatever revision is current.
+func ifMatch(c *fiber.Ctx, project *models.Project) error {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" {
+		return errPreconditionRequired
+	}
+	if header == "*" || project == nil {
+		return nil
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag(project) {
+			return nil
+		}
+	}
+	return storage.ErrRevisionConflict
+}
+
+// revisionCheck sets the ETag of GET responses to the project revision and
+// rejects mutations whose If-Match header does not name the current revision
+// before any work is done. saveProject checks it again atomically on save.
+func revisionCheck(store storage.ProjectStore) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		project := store.Get()
+		if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+			if project != nil {
+				c.Set(fiber.HeaderETag, etag(project))
+			}
+			return c.Next()
+		}
+		if err := ifMatch(c, project); err != nil {
+			return revisionError(c, err, project)
+		}
+		return c.Next()
+	}
+}
+
+// saveProject saves a project read earlier in the request, provided the
+// If-Match header still names its revision, and returns the new ETag.
+func saveProject(c *fiber.Ctx, store storage.ProjectStore, project *models.Project) error {
+	if err := ifMatch(c, project); err != nil {
+		return err
+	}
+	if err := store.Save(project); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, etag(project))
+	return nil
+}
+
+// saveError reports a failed saveProject: 428 for a missing If-Match header,
+// 409 for a revision conflict, a reference to a missing entity or a project
+// file changed on disk, and 500 otherwise.
+func saveError(c *fiber.Ctx, store storage.ProjectStore, message string, err error) error {
+	if errors.Is(err, errPreconditionRequired) || errors.Is(err, storage.ErrRevisionConflict) {
+		return revisionError(c, err, store.Get())
+	}
+	if errors.Is(err, storage.ErrDanglingReference) || errors.Is(err, storage.ErrModifiedOnDisk) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+	if errors.Is(err, storage.ErrInvalidProject) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   message,
+			"details": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
+
+func revisionError(c *fiber.Ctx, err error, current *models.Project) error {
+	if errors.Is(err, errPreconditionRequired) {
+		return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	response := fiber.Map{
+		"error": "Project was modified by someone else",
+	}
+	if current != nil {
+		c.Set(fiber.HeaderETag, etag(current))
+		response["revision"] = current.Revision
+	}
+	return c.Status(fiber.StatusConflict).JSON(response)
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/scheduler"
+	"elano.fr/src/backend/storage"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+func RegisterScheduleRoutes(app *fiber.App, store storage.ProjectStore) {
+	api := app.Group("/api/schedules", revisionCheck(store))
+
+	api.Get("/", func(c *fiber.Ctx) error {
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+		return c.JSON(project.Schedules)
+	})
+
+	api.Get("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		for _, s := range project.Schedules {
+			if s.ID == id {
+				return c.JSON(s)
+			}
+		}
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Schedule not found",
+			"id":    id,
+		})
+	})
+
+	api.Get("/:id/next", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		count, err := strconv.Atoi(c.Query("count", "5"))
+		if err != nil || count < 1 || count > 100 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Count must be between 1 and 100",
+			})
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		for _, s := range project.Schedules {
+			if s.ID == id {
+				firings, err := scheduler.NextFirings(s, project.Location, time.Now(), count)
+				if err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error":   "Failed to compute next firings",
+						"details": err.Error(),
+					})
+				}
+				return c.JSON(firings)
+			}
+		}
+
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Schedule not found",
+			"id":    id,
+		})
+	})
+
+	api.Post("/", func(c *fiber.Ctx) error {
+		var input models.Schedule
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		if err := validateSchedule(project, input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid schedule",
+				"details": err.Error(),
+			})
+		}
+
+		input.ID = uuid.NewString()
+		project.Schedules = append(project.Schedules, input)
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to save schedule", err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(input)
+	})
+
+	api.Put("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		var input models.Schedule
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		if err := validateSchedule(project, input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid schedule",
+				"details": err.Error(),
+			})
+		}
+
+		found := false
+		for i, s := range project.Schedules {
+			if s.ID == id {
+				input.ID = id
+				project.Schedules[i] = input
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Schedule not found",
+				"id":    id,
+			})
+		}
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to update schedule", err)
+		}
+
+		return c.JSON(input)
+	})
+
+	api.Delete("/:id", func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		project := store.Get()
+		if project == nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "Failed to load project",
+			})
+		}
+
+		schedules := make([]models.Schedule, 0, len(project.Schedules))
+		found := false
+
+		for _, s := range project.Schedules {
+			if s.ID != id {
+				schedules = append(schedules, s)
+			} else {
+				found = true
+			}
+		}
+
+		if !found {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Schedule not found",
+				"id":    id,
+			})
+		}
+
+		project.Schedules = schedules
+
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to delete schedule", err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
+
+func validateSchedule(project *models.Project, s models.Schedule) error {
+	if err := scheduler.Validate(s); err != nil {
+		return err
+	}
+	if _, err := scheduler.NextFirings(s, project.Location, time.Now(), 1); err != nil {
+		return err
+	}
+	actions := []models.ScheduleAction{s.Action}
+	if s.EndAction != nil {
+		actions = append(actions, *s.EndAction)
+	}
+	for _, a := range actions {
+		if a.ShowID != "" && !hasShow(project, a.ShowID) {
+			return fmt.Errorf("show %s not found", a.ShowID)
+		}
+		if a.PresetID != "" && !hasPreset(project, a.PresetID) {
+			return fmt.Errorf("preset %s not found", a.PresetID)
+		}
+	}
+	return nil
+}
+
+func hasShow(project *models.Project, id string) bool {
+	for _, s := range project.Shows {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPreset(project *models.Project, id string) bool {
+	for _, p := range project.Presets {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -9,7 +9,7 @@ import (
 )
 
 func RegisterShowRoutes(app *fiber.App, store storage.ProjectStore) {
-	api := app.Group("/api/shows")
+	api := app.Group("/api/shows", revisionCheck(store))
 
 	api.Get("/", func(c *fiber.Ctx) error {
 		project := store.Get()
@@ -111,14 +111,18 @@ func RegisterShowRoutes(app *fiber.App, store storage.ProjectStore) {
 			}
 		}
 
+		if err := storage.ValidateShowTimecodes(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid show timecode",
+				"details": "show " + err.Error(),
+			})
+		}
+
 		input.ID = uuid.NewString()
 		project.Shows = append(project.Shows, input)
 
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to save show",
-				"details": err.Error(),
-			})
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to save show", err)
 		}
 
 		return c.Status(fiber.StatusCreated).JSON(input)
@@ -194,6 +198,13 @@ func RegisterShowRoutes(app *fiber.App, store storage.ProjectStore) {
 			}
 		}
 
+		if err := storage.ValidateShowTimecodes(input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid show timecode",
+				"details": "show " + err.Error(),
+			})
+		}
+
 		found := false
 		for i, s := range project.Shows {
 			if s.ID == id {
@@ -211,58 +222,14 @@ func RegisterShowRoutes(app *fiber.App, store storage.ProjectStore) {
 			})
 		}
 
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to update show",
-				"details": err.Error(),
-			})
+		if err := saveProject(c, store, project); err != nil {
+			return saveError(c, store, "Failed to update show", err)
 		}
 
 		return c.JSON(input)
 	})
 
 	api.Delete("/:id", func(c *fiber.Ctx) error {
-		id := c.Params("id")
-		if id == "" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Show ID is required",
-			})
-		}
-
-		project := store.Get()
-		if project == nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to load project",
-			})
-		}
-
-		shows := make([]models.Show, 0, len(project.Shows))
-		found := false
-
-		for _, s := range project.Shows {
-			if s.ID != id {
-				shows = append(shows, s)
-			} else {
-				found = true
-			}
-		}
-
-		if !found {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Show not found",
-				"id":    id,
-			})
-		}
-
-		project.Shows = shows
-
-		if err := store.Save(project); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error":   "Failed to delete show",
-				"details": err.Error(),
-			})
-		}
-
-		return c.SendStatus(fiber.StatusNoContent)
+		return deleteEntity(c, store, "show", "Show")
 	})
 }
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+
+	"elano.fr/src/backend/storage"
+	"elano.fr/src/backend/ws"
+	"github.com/gofiber/fiber/v2"
+)
+
+type workspaceRequest struct {
+	Name         string `json:"name"`
+	USBInterface string `json:"usb_interface"`
+}
+
+func RegisterWorkspaceRoutes(app *fiber.App, workspace *storage.Workspace, enableDMX bool) {
+	r := app.Group("/api/workspace")
+
+	r.Get("/projects", func(c *fiber.Ctx) error {
+		projects, err := workspace.List()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Failed to list projects",
+				"details": err.Error(),
+			})
+		}
+		return c.JSON(projects)
+	})
+
+	r.Post("/projects", func(c *fiber.Ctx) error {
+		var input workspaceRequest
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+		if input.Name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Project name is required",
+			})
+		}
+		if input.USBInterface == "" {
+			input.USBInterface = "/dev/ttyUSB0"
+		}
+
+		info, err := workspace.Create(input.Name, input.USBInterface)
+		if err != nil {
+			return workspaceError(c, "Failed to create project", err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(info)
+	})
+
+	r.Post("/projects/:id/duplicate", func(c *fiber.Ctx) error {
+		var input workspaceRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&input); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "Invalid request body",
+					"details": err.Error(),
+				})
+			}
+		}
+
+		info, err := workspace.Duplicate(c.Params("id"), input.Name)
+		if err != nil {
+			return workspaceError(c, "Failed to duplicate project", err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(info)
+	})
+
+	r.Put("/projects/:id", func(c *fiber.Ctx) error {
+		var input workspaceRequest
+		if err := c.BodyParser(&input); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+		if input.Name == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Project name is required",
+			})
+		}
+
+		if err := workspace.Rename(c.Params("id"), input.Name); err != nil {
+			return workspaceError(c, "Failed to rename project", err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	r.Post("/projects/:id/archive", func(c *fiber.Ctx) error {
+		if err := workspace.Archive(c.Params("id")); err != nil {
+			return workspaceError(c, "Failed to archive project", err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	r.Post("/projects/:id/restore", func(c *fiber.Ctx) error {
+		if err := workspace.Restore(c.Params("id")); err != nil {
+			return workspaceError(c, "Failed to restore project", err)
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	r.Post("/projects/:id/switch", func(c *fiber.Ctx) error {
+		project, err := workspace.Switch(c.Params("id"))
+		if err != nil {
+			return workspaceError(c, "Failed to switch project", err)
+		}
+
+		ws.ProjectSwitched()
+
+		if enableDMX {
+			if err := ws.InitializeDMXController(project.USBInterface); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "Failed to initialize DMX controller",
+					"details": err.Error(),
+				})
+			}
+		}
+
+		c.Set(fiber.HeaderETag, etag(project))
+		return c.JSON(project)
+	})
+}
+
+func workspaceError(c *fiber.Ctx, message string, err error) error {
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, storage.ErrProjectNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, storage.ErrProjectActive), errors.Is(err, storage.ErrRevisionConflict):
+		status = fiber.StatusConflict
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is what a client may do. Each role includes the ones below it.
+type Role int
+
+const (
+	// None is the role of a client without a valid token.
+	None Role = iota
+	// Viewer can only see the project and the live state.
+	Viewer
+	// Operator can also run shows and presets and drive the output.
+	Operator
+	// Programmer can also edit the project.
+	Programmer
+	// Admin can also import, restore, switch and delete projects, and
+	// manage tokens.
+	Admin
+)
+
+var roleNames = []string{"none", "viewer", "operator", "programmer", "admin"}
+
+func (r Role) String() string {
+	if r < None || int(r) >= len(roleNames) {
+		return fmt.Sprintf("role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// Allows reports whether r includes required.
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+func ParseRole(s string) (Role, error) {
+	for i, name := range roleNames {
+		if i > 0 && strings.EqualFold(s, name) {
+			return Role(i), nil
+		}
+	}
+	return None, fmt.Errorf("unknown role %q", s)
+}
+
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := ParseRole(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
+
+// Identity is who sent a request.
+type Identity struct {
+	TokenID string `json:"token_id,omitempty"`
+	Name    string `json:"name"`
+	Role    Role   `json:"role"`
+}
+
+// Anonymous is the identity given to every client until the first token is
+// created. It can only look, so that nobody on the network can drive the
+// output or take over the project before authentication is set up.
+var Anonymous = &Identity{Name: "anonymous", Role: Viewer}
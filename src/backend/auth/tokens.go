@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrTokenNotFound = errors.New("token not found")
+	ErrLastAdmin     = errors.New("cannot revoke the last admin token")
+)
+
+// Token is an API token. Only the SHA-256 hash of its secret is kept.
+type Token struct {
+	ID      string    `yaml:"id" json:"id"`
+	Name    string    `yaml:"name" json:"name"`
+	Role    Role      `yaml:"role" json:"role"`
+	Hash    string    `yaml:"hash" json:"-"`
+	Created time.Time `yaml:"created" json:"created"`
+}
+
+type tokenFile struct {
+	Tokens []Token `yaml:"tokens"`
+}
+
+// Tokens is the set of API tokens, persisted in a YAML file. Authentication
+// is enabled as soon as there is a token, either in the file or given as the
+// admin token at startup.
+type Tokens struct {
+	mu     sync.RWMutex
+	path   string
+	tokens []Token
+	admin  *Token
+}
+
+// OpenTokens reads the tokens in path, which may not exist yet. A non-empty
+// adminToken is accepted as an admin token that is never written to disk.
+func OpenTokens(path, adminToken string) (*Tokens, error) {
+	t := &Tokens{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read tokens %q: %w", path, err)
+	}
+	if err == nil {
+		var f tokenFile
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("failed to decode tokens %q: %w", path, err)
+		}
+		for _, token := range f.Tokens {
+			if token.ID == "" || token.Hash == "" || token.Role == None {
+				return nil, fmt.Errorf("invalid token %q in %q", token.Name, path)
+			}
+		}
+		t.tokens = f.Tokens
+	}
+	if adminToken != "" {
+		t.admin = &Token{ID: "admin", Name: "admin", Role: Admin, Hash: hashSecret(adminToken)}
+	}
+	return t, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t *Tokens) Enabled() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.admin != nil || len(t.tokens) > 0
+}
+
+// Authenticate returns the identity of the token with the given secret.
+func (t *Tokens) Authenticate(secret string) (*Identity, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	hash := []byte(hashSecret(secret))
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	candidates := t.tokens
+	if t.admin != nil {
+		candidates = append([]Token{*t.admin}, candidates...)
+	}
+	for _, token := range candidates {
+		if subtle.ConstantTimeCompare(hash, []byte(token.Hash)) == 1 {
+			return &Identity{TokenID: token.ID, Name: token.Name, Role: token.Role}, true
+		}
+	}
+	return nil, false
+}
+
+// Refresh returns the current identity of a client authenticated earlier,
+// for connections that outlive a request. It fails once the token of the
+// client has been revoked.
+func (t *Tokens) Refresh(id *Identity) (*Identity, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.admin == nil && len(t.tokens) == 0 {
+		return Anonymous, true
+	}
+	if id == nil || id.TokenID == "" {
+		return nil, false
+	}
+	if t.admin != nil && id.TokenID == t.admin.ID {
+		return &Identity{TokenID: t.admin.ID, Name: t.admin.Name, Role: t.admin.Role}, true
+	}
+	for _, token := range t.tokens {
+		if token.ID == id.TokenID {
+			return &Identity{TokenID: token.ID, Name: token.Name, Role: token.Role}, true
+		}
+	}
+	return nil, false
+}
+
+// hasAdmin reports whether one of tokens is an admin token.
+func hasAdmin(tokens []Token) bool {
+	for _, token := range tokens {
+		if token.Role == Admin {
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the tokens stored in the file.
+func (t *Tokens) List() []Token {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]Token{}, t.tokens...)
+}
+
+// Create adds a token and returns it along with its secret, which cannot be
+// recovered afterwards. Without an admin token given at startup, the first
+// token must be an admin token, or nobody could manage the tokens once
+// authentication is enabled.
+func (t *Tokens) Create(name string, role Role) (Token, string, error) {
+	if name == "" {
+		return Token{}, "", fmt.Errorf("token name is required")
+	}
+	if role == None {
+		return Token{}, "", fmt.Errorf("token role is required")
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return Token{}, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	token := Token{ID: uuid.NewString(), Name: name, Role: role, Hash: hashSecret(secret), Created: time.Now().UTC()}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.admin == nil && role != Admin && !hasAdmin(t.tokens) {
+		return Token{}, "", fmt.Errorf("the first token must be an admin token")
+	}
+	tokens := append(append([]Token{}, t.tokens...), token)
+	if err := t.write(tokens); err != nil {
+		return Token{}, "", err
+	}
+	t.tokens = tokens
+	return token, secret, nil
+}
+
+// Revoke removes a token. Without an admin token given at startup, the last
+// admin token cannot be revoked, nor the last token: nobody could manage the
+// tokens any more, and only the server itself could create a new one.
+func (t *Tokens) Revoke(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var revoked *Token
+	tokens := make([]Token, 0, len(t.tokens))
+	for i, token := range t.tokens {
+		if token.ID == id {
+			revoked = &t.tokens[i]
+		} else {
+			tokens = append(tokens, token)
+		}
+	}
+	if revoked == nil {
+		return ErrTokenNotFound
+	}
+	if t.admin == nil && (len(tokens) == 0 || revoked.Role == Admin && !hasAdmin(tokens)) {
+		return ErrLastAdmin
+	}
+	if err := t.write(tokens); err != nil {
+		return err
+	}
+	t.tokens = tokens
+	return nil
+}
+
+func (t *Tokens) write(tokens []Token) error {
+	data, err := yaml.Marshal(tokenFile{Tokens: tokens})
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tempFile := t.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	if err := os.Rename(tempFile, t.path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package chase
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"elano.fr/src/backend/models"
+)
+
+type Pattern string
+
+const (
+	Sequential Pattern = "sequential"
+	Bounce     Pattern = "bounce"
+	Random     Pattern = "random"
+	Pairs      Pattern = "pairs"
+	BuildUp    Pattern = "build_up"
+)
+
+// Look maps fixture channel names (case-insensitive) to the value they take
+// when a fixture is lit in a step.
+type Look map[string]int
+
+func ValidatePattern(p Pattern) error {
+	switch p {
+	case Sequential, Bounce, Random, Pairs, BuildUp:
+		return nil
+	}
+	return fmt.Errorf("unknown chase pattern %q", p)
+}
+
+// Generate returns the channel values of every step of the chase, lighting the
+// fixtures in the order given according to the pattern.
+func Generate(fixtures []models.Fixture, look Look, pattern Pattern, seed int64) ([]map[int]byte, error) {
+	if err := ValidatePattern(pattern); err != nil {
+		return nil, err
+	}
+	if len(fixtures) == 0 {
+		return nil, fmt.Errorf("at least one fixture is required")
+	}
+	if len(look) == 0 {
+		return nil, fmt.Errorf("look must set at least one channel")
+	}
+	for name, v := range look {
+		if v < 0 || v > 255 {
+			return nil, fmt.Errorf("look value for %q must be 0-255, got %d", name, v)
+		}
+	}
+
+	values := make([]map[int]byte, len(fixtures))
+	for i, f := range fixtures {
+		values[i] = make(map[int]byte)
+		for _, ch := range f.Channels {
+			for name, v := range look {
+				if strings.EqualFold(ch.Name, name) {
+					values[i][ch.ChannelAddress] = byte(v)
+				}
+			}
+		}
+		if len(values[i]) == 0 {
+			return nil, fmt.Errorf("fixture %q has none of the look channels", f.Name)
+		}
+	}
+
+	var steps []map[int]byte
+	for _, lit := range order(len(fixtures), pattern, seed) {
+		step := make(map[int]byte)
+		for _, fi := range lit {
+			for addr, v := range values[fi] {
+				step[addr] = v
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func order(n int, pattern Pattern, seed int64) [][]int {
+	var steps [][]int
+	switch pattern {
+	case Sequential:
+		for i := 0; i < n; i++ {
+			steps = append(steps, []int{i})
+		}
+	case Bounce:
+		for i := 0; i < n; i++ {
+			steps = append(steps, []int{i})
+		}
+		for i := n - 2; i > 0; i-- {
+			steps = append(steps, []int{i})
+		}
+	case Random:
+		for _, i := range rand.New(rand.NewSource(seed)).Perm(n) {
+			steps = append(steps, []int{i})
+		}
+	case Pairs:
+		for i := 0; i < n; i += 2 {
+			if i+1 < n {
+				steps = append(steps, []int{i, i + 1})
+			} else {
+				steps = append(steps, []int{i})
+			}
+		}
+	case BuildUp:
+		for i := 0; i < n; i++ {
+			lit := make([]int, i+1)
+			for j := range lit {
+				lit[j] = j
+			}
+			steps = append(steps, lit)
+		}
+	}
+	return steps
+}
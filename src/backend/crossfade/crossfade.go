@@ -0,0 +1,82 @@
+package crossfade
+
+import (
+	"math"
+
+	"elano.fr/src/backend/models"
+)
+
+// Rules describes how channels are interpolated. Fine maps the coarse address
+// of a 16-bit channel to its fine address; Snap channels (gobos, color wheels
+// and other LTP attributes) jump from A to B at the midpoint instead of
+// passing through intermediate values.
+type Rules struct {
+	Fine map[int]int
+	Snap map[int]bool
+}
+
+func RulesFromFixtures(fixtures []models.Fixture) Rules {
+	r := Rules{Fine: make(map[int]int), Snap: make(map[int]bool)}
+	for _, f := range fixtures {
+		for _, ch := range f.Channels {
+			if ch.FineChannelAddress > 0 {
+				r.Fine[ch.ChannelAddress] = ch.FineChannelAddress
+			}
+			if ch.Snap {
+				r.Snap[ch.ChannelAddress] = true
+				if ch.FineChannelAddress > 0 {
+					r.Snap[ch.FineChannelAddress] = true
+				}
+			}
+		}
+	}
+	return r
+}
+
+// Mix returns the value of every channel used by a or b at position pos, from
+// 0 (all A) to 1 (all B). Channels missing from one side are taken as zero.
+func Mix(a, b map[int]byte, pos float64, r Rules) map[int]byte {
+	pos = math.Max(0, math.Min(1, pos))
+	out := make(map[int]byte, len(a)+len(b))
+
+	fine := make(map[int]bool, len(r.Fine))
+	for coarse, f := range r.Fine {
+		if r.Snap[coarse] {
+			continue
+		}
+		_, inA := a[coarse]
+		_, inB := b[coarse]
+		if !inA && !inB {
+			continue
+		}
+		va := int(a[coarse])<<8 | int(a[f])
+		vb := int(b[coarse])<<8 | int(b[f])
+		v := int(math.Round(float64(va) + float64(vb-va)*pos))
+		out[coarse] = byte(v >> 8)
+		out[f] = byte(v)
+		fine[f] = true
+	}
+
+	mix := func(addr int) {
+		if _, done := out[addr]; done || fine[addr] {
+			return
+		}
+		if r.Snap[addr] {
+			if pos < 0.5 {
+				out[addr] = a[addr]
+			} else {
+				out[addr] = b[addr]
+			}
+			return
+		}
+		va, vb := float64(a[addr]), float64(b[addr])
+		out[addr] = byte(math.Round(va + (vb-va)*pos))
+	}
+	for addr := range a {
+		mix(addr)
+	}
+	for addr := range b {
+		mix(addr)
+	}
+	return out
+}
@@ -37,6 +37,18 @@ type ChannelLimit struct {
 	Max byte
 }
 
+type FrameProcessor func(now time.Time, channels []byte)
+
+type namedProcessor struct {
+	name string
+	fn   FrameProcessor
+}
+
+type Submaster struct {
+	Values map[int]byte
+	Level  float64
+}
+
 type DMXController struct {
 	port serial.Port
 	data [DMXFrameSize]byte
@@ -48,6 +60,8 @@ type DMXController struct {
 
 	masterDimmer  float64
 	channelLimits map[int]*ChannelLimit
+	submasters    map[string]*Submaster
+	processors    []namedProcessor
 
 	fadeMu     sync.Mutex
 	fadeCancel context.CancelFunc
@@ -82,6 +96,7 @@ func NewDMXController(portName string) (*DMXController, error) {
 		dataChanged:   make(chan struct{}, 2),
 		masterDimmer:  1.0,
 		channelLimits: make(map[int]*ChannelLimit),
+		submasters:    make(map[string]*Submaster),
 	}
 
 	d.data[0] = DMXStartCode
@@ -124,16 +139,39 @@ func (d *DMXController) sendFrame() {
 	}
 	time.Sleep(DMXMaBTime)
 
+	frame := d.renderFrame()
+
+	if _, err := d.port.Write(frame[:]); err != nil {
+		d.errorCount.Add(1)
+		return
+	}
+	d.port.Drain()
+}
+
+func (d *DMXController) renderFrame() [DMXFrameSize]byte {
 	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	frame := d.data
-	master := d.masterDimmer
-	limits := d.channelLimits
-	d.mu.RUnlock()
+	for _, sub := range d.submasters {
+		for ch, v := range sub.Values {
+			if s := byte(float64(v) * sub.Level); s > frame[ch] {
+				frame[ch] = s
+			}
+		}
+	}
+
+	if len(d.processors) > 0 {
+		now := time.Now()
+		for _, p := range d.processors {
+			p.fn(now, frame[1:])
+		}
+	}
 
 	for i := 1; i <= DMXChannels; i++ {
-		v := float64(frame[i]) * master
+		v := float64(frame[i]) * d.masterDimmer
 		b := byte(v)
-		if lim, ok := limits[i]; ok {
+		if lim, ok := d.channelLimits[i]; ok {
 			if b < lim.Min {
 				b = lim.Min
 			} else if b > lim.Max {
@@ -142,12 +180,7 @@ func (d *DMXController) sendFrame() {
 		}
 		frame[i] = b
 	}
-
-	if _, err := d.port.Write(frame[:]); err != nil {
-		d.errorCount.Add(1)
-		return
-	}
-	d.port.Drain()
+	return frame
 }
 
 func (d *DMXController) SetChannel(ch int, value byte) error {
@@ -269,6 +302,74 @@ func (d *DMXController) RemoveChannelLimit(ch int) error {
 	return nil
 }
 
+func (d *DMXController) SetSubmaster(id string, values map[int]byte, level float64) error {
+	if level < 0 || level > 1 {
+		return fmt.Errorf("submaster level must be 0.0-1.0, got %f", level)
+	}
+	sub := &Submaster{Values: make(map[int]byte, len(values)), Level: level}
+	for ch, v := range values {
+		if ch < 1 || ch > DMXChannels {
+			return fmt.Errorf("channel must be 1-%d, got %d", DMXChannels, ch)
+		}
+		sub.Values[ch] = v
+	}
+	d.mu.Lock()
+	d.submasters[id] = sub
+	d.mu.Unlock()
+	d.signalChange()
+	return nil
+}
+
+func (d *DMXController) SetSubmasterLevel(id string, level float64) error {
+	if level < 0 || level > 1 {
+		return fmt.Errorf("submaster level must be 0.0-1.0, got %f", level)
+	}
+	d.mu.Lock()
+	sub, ok := d.submasters[id]
+	if ok {
+		sub.Level = level
+	}
+	d.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("submaster %q not found", id)
+	}
+	d.signalChange()
+	return nil
+}
+
+func (d *DMXController) RemoveSubmaster(id string) {
+	d.mu.Lock()
+	delete(d.submasters, id)
+	d.mu.Unlock()
+	d.signalChange()
+}
+
+// SetFrameProcessor registers fn to modify every outgoing frame after static
+// levels and submasters are combined. Processors run in registration order and
+// must not call back into the controller.
+func (d *DMXController) SetFrameProcessor(name string, fn FrameProcessor) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, p := range d.processors {
+		if p.name == name {
+			d.processors[i].fn = fn
+			return
+		}
+	}
+	d.processors = append(d.processors, namedProcessor{name: name, fn: fn})
+}
+
+func (d *DMXController) RemoveFrameProcessor(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, p := range d.processors {
+		if p.name == name {
+			d.processors = append(d.processors[:i], d.processors[i+1:]...)
+			return
+		}
+	}
+}
+
 func (d *DMXController) Blackout() error {
 	if d.closed.Load() {
 		return fmt.Errorf("controller is closed")
@@ -341,6 +442,17 @@ func (d *DMXController) GetAllChannels() ([]byte, error) {
 	return out, nil
 }
 
+func (d *DMXController) GetOutputChannels() ([]byte, error) {
+	if d.closed.Load() {
+		return nil, fmt.Errorf("controller is closed")
+	}
+	frame := d.renderFrame()
+
+	out := make([]byte, DMXChannels)
+	copy(out, frame[1:])
+	return out, nil
+}
+
 func (d *DMXController) signalChange() {
 	select {
 	case d.dataChanged <- struct{}{}:
@@ -348,6 +460,23 @@ func (d *DMXController) signalChange() {
 	}
 }
 
+func ParseFadeMode(name string) (FadeMode, error) {
+	switch name {
+	case "", "linear":
+		return FadeLinear, nil
+	case "quadratic":
+		return FadeQuadratic, nil
+	case "cubic":
+		return FadeCubic, nil
+	case "sine":
+		return FadeSine, nil
+	case "exponential":
+		return FadeExponential, nil
+	default:
+		return FadeLinear, fmt.Errorf("unknown fade curve %q", name)
+	}
+}
+
 func applyFadeCurve(progress float64, mode FadeMode) float64 {
 	switch mode {
 	case FadeLinear:
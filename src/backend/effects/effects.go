@@ -0,0 +1,174 @@
+package effects
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Waveform string
+
+const (
+	Sine     Waveform = "sine"
+	Square   Waveform = "square"
+	Sawtooth Waveform = "sawtooth"
+	Ramp     Waveform = "ramp"
+	Random   Waveform = "random"
+)
+
+type Effect struct {
+	ID       string   `json:"id"`
+	Waveform Waveform `json:"waveform"`
+	Speed    float64  `json:"speed"`
+	Size     float64  `json:"size"`
+	Offset   float64  `json:"offset"`
+	Phase    float64  `json:"phase"`
+	// Targets holds one group of DMX addresses per fixture (or per channel);
+	// the phase spread is distributed across the groups in order.
+	Targets [][]int `json:"targets"`
+}
+
+type running struct {
+	Effect
+	start time.Time
+	seed  uint64
+}
+
+type Engine struct {
+	mu      sync.RWMutex
+	effects map[string]*running
+	seq     uint64
+}
+
+func NewEngine() *Engine {
+	return &Engine{effects: make(map[string]*running)}
+}
+
+func Validate(e Effect) error {
+	switch e.Waveform {
+	case Sine, Square, Sawtooth, Ramp, Random:
+	default:
+		return fmt.Errorf("unknown waveform %q", e.Waveform)
+	}
+	if e.Speed < 0 || e.Speed > 50 {
+		return fmt.Errorf("speed must be 0-50 Hz, got %g", e.Speed)
+	}
+	if e.Size < 0 || e.Size > 255 {
+		return fmt.Errorf("size must be 0-255, got %g", e.Size)
+	}
+	if e.Offset < -255 || e.Offset > 255 {
+		return fmt.Errorf("offset must be -255 to 255, got %g", e.Offset)
+	}
+	if len(e.Targets) == 0 {
+		return fmt.Errorf("effect has no target channels")
+	}
+	for _, group := range e.Targets {
+		for _, ch := range group {
+			if ch < 1 || ch > 512 {
+				return fmt.Errorf("channel must be 1-512, got %d", ch)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Engine) Start(ef Effect, now time.Time) error {
+	if ef.ID == "" {
+		return fmt.Errorf("effect ID is required")
+	}
+	if err := Validate(ef); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.seq++
+	e.effects[ef.ID] = &running{Effect: ef, start: now, seed: e.seq*0x9E3779B97F4A7C15 + uint64(now.UnixNano())}
+	return nil
+}
+
+func (e *Engine) Stop(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.effects[id]
+	delete(e.effects, id)
+	return ok
+}
+
+func (e *Engine) StopAll() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids := make([]string, 0, len(e.effects))
+	for id := range e.effects {
+		ids = append(ids, id)
+	}
+	e.effects = make(map[string]*running)
+	sort.Strings(ids)
+	return ids
+}
+
+func (e *Engine) List() []Effect {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]Effect, 0, len(e.effects))
+	for _, r := range e.effects {
+		out = append(out, r.Effect)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Apply adds the current value of every running effect to channels, which is
+// indexed by DMX address minus one.
+func (e *Engine) Apply(now time.Time, channels []byte) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, r := range e.effects {
+		elapsed := now.Sub(r.start).Seconds()
+		n := float64(len(r.Targets))
+		for gi, group := range r.Targets {
+			pos := elapsed*r.Speed + r.Phase/360*float64(gi)/n
+			v := r.Offset + r.Size*r.sample(pos, gi)
+			for _, ch := range group {
+				if ch < 1 || ch > len(channels) {
+					continue
+				}
+				out := float64(channels[ch-1]) + v
+				channels[ch-1] = byte(math.Max(0, math.Min(255, math.Round(out))))
+			}
+		}
+	}
+}
+
+func (r *running) sample(pos float64, group int) float64 {
+	cycle, frac := math.Modf(pos)
+	if frac < 0 {
+		frac++
+		cycle--
+	}
+	switch r.Waveform {
+	case Sine:
+		return (1 - math.Cos(2*math.Pi*frac)) / 2
+	case Square:
+		if frac < 0.5 {
+			return 1
+		}
+		return 0
+	case Sawtooth:
+		return 1 - frac
+	case Ramp:
+		return frac
+	case Random:
+		// Sample-and-hold: a new pseudo-random level per cycle and group.
+		return float64(splitmix(r.seed^uint64(int64(cycle))*31^uint64(group)<<32)>>11) / (1 << 53)
+	}
+	return 0
+}
+
+func splitmix(x uint64) uint64 {
+	x += 0x9E3779B97F4A7C15
+	x = (x ^ (x >> 30)) * 0xBF58476D1CE4E5B9
+	x = (x ^ (x >> 27)) * 0x94D049BB133111EB
+	return x ^ (x >> 31)
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"elano.fr/src/backend/api"
+	"elano.fr/src/backend/auth"
+	"elano.fr/src/backend/scheduler"
 	"elano.fr/src/backend/storage"
 	"elano.fr/src/backend/utils"
 	"elano.fr/src/backend/ws"
@@ -31,20 +33,31 @@ func main() {
 		log.Fatalf("Failed to create data directory: %v", err)
 	}
 
-	store, err := storage.NewYAMLStore(config.DataFilePath)
+	workspace, err := storage.OpenWorkspace(config.ProjectsDir, config.DataFilePath, config.ProjectFormat)
 	if err != nil {
-		log.Printf("Warning: failed to load project: %v", err)
-		log.Println("Creating default project...")
-		store, err = storage.NewYAMLStoreWithDefault(config.DataFilePath)
-		if err != nil {
-			log.Fatalf("Failed to create default project: %v", err)
-		}
+		log.Fatalf("Failed to open project workspace: %v", err)
 	}
+	workspace.SetBackupPolicy(storage.BackupPolicy{
+		Interval:   config.BackupInterval,
+		EverySaves: config.BackupEverySaves,
+		Keep:       config.BackupKeep,
+		MaxAge:     config.BackupMaxAge,
+	})
 
-	ws.SetProjectStore(store)
+	tokens, err := auth.OpenTokens(config.AuthFile, config.AdminToken)
+	if err != nil {
+		log.Fatalf("Failed to open API tokens: %v", err)
+	}
+	if !tokens.Enabled() {
+		log.Println("Warning: no API token, clients can only view until the first admin token is created with POST /api/auth/tokens from this machine (or set ADMIN_TOKEN)")
+	}
+
+	ws.SetProjectStore(workspace)
+	ws.SetTokens(tokens)
+	ws.SetMediaDir(config.MediaDir)
 
 	dmxPort := ""
-	if project := store.Get(); project != nil {
+	if project := workspace.Get(); project != nil {
 		dmxPort = project.USBInterface
 	}
 
@@ -82,11 +95,14 @@ func main() {
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     utils.GetEnv("CORS_ORIGINS", "*"),
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, If-Match",
+		ExposeHeaders:    "ETag",
 		AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS",
 		AllowCredentials: false,
 	}))
 
+	api.RegisterAuthRoutes(app, tokens)
+
 	app.Get("/health", func(c *fiber.Ctx) error {
 		dmxStatus := "disabled"
 		if config.EnableDMX {
@@ -102,10 +118,17 @@ func main() {
 	})
 
 	api.RegisterUSBRoutes(app)
-	api.RegisterFixtureRoutes(app, store)
-	api.RegisterPresetRoutes(app, store)
-	api.RegisterShowRoutes(app, store)
-	api.RegisterProjectRoutes(app, store, config.EnableDMX)
+	api.RegisterFixtureRoutes(app, workspace)
+	api.RegisterPresetRoutes(app, workspace)
+	api.RegisterShowRoutes(app, workspace)
+	api.RegisterScheduleRoutes(app, workspace)
+	api.RegisterChaseRoutes(app, workspace)
+	api.RegisterPixelMapRoutes(app, workspace)
+	api.RegisterPaletteRoutes(app, workspace)
+	api.RegisterProjectRoutes(app, workspace, config.EnableDMX)
+	api.RegisterHistoryRoutes(app, workspace)
+	api.RegisterBackupRoutes(app, workspace, config.EnableDMX)
+	api.RegisterWorkspaceRoutes(app, workspace, config.EnableDMX)
 
 	if config.EnableDMX {
 		log.Printf("Initializing DMX controller on %s...", dmxPort)
@@ -121,6 +144,21 @@ func main() {
 
 	ws.SetupWebSocketRoutes(app)
 
+	sched := scheduler.New(workspace.Get, ws.RunAction)
+	sched.Start()
+
+	stopWatch := workspace.Watch(config.ProjectWatchInterval, ws.ProjectReloaded)
+
+	if config.MTCDevice != "" {
+		ws.StartMTCListener(config.MTCDevice)
+	}
+	if config.OSCAddress != "" {
+		ws.StartOSCListener(config.OSCAddress)
+	}
+	if config.MIDIDevice != "" {
+		ws.StartMIDIListener(config.MIDIDevice, config.CrossfadeCC)
+	}
+
 	app.Use("/", filesystem.New(filesystem.Config{
 		Root:         http.FS(frontend.DistFS),
 		Index:        "index.html",
@@ -137,6 +175,9 @@ func main() {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
+		sched.Stop()
+		stopWatch()
+
 		if err := ws.CloseDMXController(); err != nil {
 			log.Printf("Error closing DMX controller: %v", err)
 		}
@@ -149,7 +190,7 @@ func main() {
 	}()
 
 	log.Printf("🚀 Server starting on http://localhost%s", config.ServerPort)
-	log.Printf("📁 Data file: %s", config.DataFilePath)
+	log.Printf("📁 Projects: %s (active: %s)", config.ProjectsDir, workspace.ActiveID())
 	log.Printf("🎛️  DMX: %s", func() string {
 		if config.EnableDMX {
 			return "enabled on " + dmxPort
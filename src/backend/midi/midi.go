@@ -0,0 +1,88 @@
+package midi
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type ControlChange struct {
+	Channel    int
+	Controller int
+	Value      int
+}
+
+// CCDecoder extracts control change messages from a raw MIDI byte stream,
+// honouring running status and skipping SysEx and real-time bytes.
+type CCDecoder struct {
+	status  byte
+	data    [2]byte
+	n       int
+	inSysEx bool
+}
+
+func (d *CCDecoder) Feed(b byte) (ControlChange, bool) {
+	if b >= 0xF8 {
+		return ControlChange{}, false
+	}
+	if b >= 0x80 {
+		d.inSysEx = b == 0xF0
+		d.n = 0
+		if b < 0xF0 {
+			d.status = b
+		} else {
+			d.status = 0
+		}
+		return ControlChange{}, false
+	}
+	if d.inSysEx || d.status == 0 {
+		return ControlChange{}, false
+	}
+	d.data[d.n] = b
+	d.n++
+	if d.n < dataLength(d.status) {
+		return ControlChange{}, false
+	}
+	d.n = 0
+	if d.status&0xF0 != 0xB0 {
+		return ControlChange{}, false
+	}
+	return ControlChange{Channel: int(d.status&0x0F) + 1, Controller: int(d.data[0]), Value: int(d.data[1])}, true
+}
+
+func dataLength(status byte) int {
+	switch status & 0xF0 {
+	case 0xC0, 0xD0:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// ReadCC decodes control changes from r until it fails.
+func ReadCC(r io.Reader, handle func(ControlChange)) error {
+	var dec CCDecoder
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			if cc, ok := dec.Feed(b); ok {
+				handle(cc)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ListenCC reads control changes from a raw MIDI device such as
+// /dev/snd/midiC1D0.
+func ListenCC(device string, handle func(ControlChange)) error {
+	f, err := os.Open(device)
+	if err != nil {
+		return fmt.Errorf("failed to open MIDI device %q: %w", device, err)
+	}
+	defer f.Close()
+	return ReadCC(f, handle)
+}
@@ -9,9 +9,11 @@ type Fixture struct {
 }
 
 type FixtureChannel struct {
-	Name           string `yaml:"name" json:"name"`
-	Description    string `yaml:"description" json:"description"`
-	Min            int    `yaml:"min" json:"min"`
-	Max            int    `yaml:"max" json:"max"`
-	ChannelAddress int    `yaml:"channel_address" json:"channel_address"`
+	Name               string `yaml:"name" json:"name"`
+	Description        string `yaml:"description" json:"description"`
+	Min                int    `yaml:"min" json:"min"`
+	Max                int    `yaml:"max" json:"max"`
+	ChannelAddress     int    `yaml:"channel_address" json:"channel_address"`
+	FineChannelAddress int    `yaml:"fine_channel_address,omitempty" json:"fine_channel_address,omitempty"`
+	Snap               bool   `yaml:"snap,omitempty" json:"snap,omitempty"`
 }
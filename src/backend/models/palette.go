@@ -0,0 +1,19 @@
+package models
+
+const (
+	PaletteColor    = "color"
+	PalettePosition = "position"
+	PaletteBeam     = "beam"
+)
+
+type Palette struct {
+	ID     string         `yaml:"id" json:"id"`
+	Name   string         `yaml:"name" json:"name"`
+	Type   string         `yaml:"type" json:"type"`
+	Values map[string]int `yaml:"values" json:"values"`
+}
+
+type PaletteRef struct {
+	PaletteID  string   `yaml:"palette_id" json:"palette_id"`
+	FixtureIDs []string `yaml:"fixture_ids" json:"fixture_ids"`
+}
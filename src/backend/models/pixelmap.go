@@ -0,0 +1,18 @@
+package models
+
+type PixelMap struct {
+	ID     string      `yaml:"id" json:"id"`
+	Name   string      `yaml:"name" json:"name"`
+	Width  int         `yaml:"width" json:"width"`
+	Height int         `yaml:"height" json:"height"`
+	Cells  []PixelCell `yaml:"cells" json:"cells"`
+}
+
+type PixelCell struct {
+	X         int    `yaml:"x" json:"x"`
+	Y         int    `yaml:"y" json:"y"`
+	FixtureID string `yaml:"fixture_id,omitempty" json:"fixture_id,omitempty"`
+	Red       int    `yaml:"red,omitempty" json:"red,omitempty"`
+	Green     int    `yaml:"green,omitempty" json:"green,omitempty"`
+	Blue      int    `yaml:"blue,omitempty" json:"blue,omitempty"`
+}
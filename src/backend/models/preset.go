@@ -5,6 +5,7 @@ type Preset struct {
 	Name        string         `yaml:"name" json:"name"`
 	Description string         `yaml:"description" json:"description"`
 	Channels    []ChannelValue `yaml:"channels" json:"channels"`
+	Palettes    []PaletteRef   `yaml:"palettes,omitempty" json:"palettes,omitempty"`
 }
 
 type ChannelValue struct {
@@ -1,10 +1,18 @@
 package models
 
 type Project struct {
-	ID           string    `yaml:"id" json:"id"`
-	Name         string    `yaml:"name" json:"name"`
-	USBInterface string    `yaml:"usb_interface" json:"usb_interface"`
-	Fixtures     []Fixture `yaml:"fixtures" json:"fixtures"`
-	Presets      []Preset  `yaml:"presets" json:"presets"`
-	Shows        []Show    `yaml:"shows" json:"shows"`
+	SchemaVersion int        `yaml:"schema_version" json:"schema_version"`
+	ID            string     `yaml:"id" json:"id"`
+	Name          string     `yaml:"name" json:"name"`
+	USBInterface  string     `yaml:"usb_interface" json:"usb_interface"`
+	Fixtures      []Fixture  `yaml:"fixtures" json:"fixtures"`
+	Presets       []Preset   `yaml:"presets" json:"presets"`
+	Shows         []Show     `yaml:"shows" json:"shows"`
+	Schedules     []Schedule `yaml:"schedules,omitempty" json:"schedules"`
+	PixelMaps     []PixelMap `yaml:"pixel_maps,omitempty" json:"pixel_maps"`
+	Palettes      []Palette  `yaml:"palettes,omitempty" json:"palettes"`
+	Location      *Location  `yaml:"location,omitempty" json:"location,omitempty"`
+	// Revision is kept in memory by the store to detect concurrent saves.
+	// It is not written to project files.
+	Revision int64 `yaml:"-" json:"revision"`
 }
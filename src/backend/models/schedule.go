@@ -0,0 +1,37 @@
+package models
+
+const (
+	ActionRunShow     = "run_show"
+	ActionApplyPreset = "apply_preset"
+	ActionBlackout    = "blackout"
+)
+
+type Schedule struct {
+	ID        string          `yaml:"id" json:"id"`
+	Name      string          `yaml:"name" json:"name"`
+	Enabled   bool            `yaml:"enabled" json:"enabled"`
+	Cron      string          `yaml:"cron,omitempty" json:"cron,omitempty"`
+	Days      []int           `yaml:"days,omitempty" json:"days,omitempty"`
+	Start     *ScheduleTime   `yaml:"start,omitempty" json:"start,omitempty"`
+	End       *ScheduleTime   `yaml:"end,omitempty" json:"end,omitempty"`
+	Action    ScheduleAction  `yaml:"action" json:"action"`
+	EndAction *ScheduleAction `yaml:"end_action,omitempty" json:"end_action,omitempty"`
+}
+
+type ScheduleTime struct {
+	Time          string `yaml:"time,omitempty" json:"time,omitempty"`
+	Sun           string `yaml:"sun,omitempty" json:"sun,omitempty"`
+	OffsetMinutes int    `yaml:"offset_minutes,omitempty" json:"offset_minutes,omitempty"`
+}
+
+type ScheduleAction struct {
+	Type     string `yaml:"type" json:"type"`
+	ShowID   string `yaml:"show_id,omitempty" json:"show_id,omitempty"`
+	PresetID string `yaml:"preset_id,omitempty" json:"preset_id,omitempty"`
+	Loop     bool   `yaml:"loop,omitempty" json:"loop,omitempty"`
+}
+
+type Location struct {
+	Latitude  float64 `yaml:"latitude" json:"latitude"`
+	Longitude float64 `yaml:"longitude" json:"longitude"`
+}
@@ -0,0 +1,166 @@
+package osc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+)
+
+type Message struct {
+	Address string
+	Args    []interface{}
+}
+
+func Parse(data []byte) ([]Message, error) {
+	if bytes.HasPrefix(data, []byte("#bundle\x00")) {
+		return parseBundle(data)
+	}
+	msg, err := parseMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	return []Message{msg}, nil
+}
+
+func parseBundle(data []byte) ([]Message, error) {
+	// "#bundle\0" followed by an 8 byte time tag, then size-prefixed elements.
+	if len(data) < 16 {
+		return nil, fmt.Errorf("OSC bundle too short")
+	}
+	var out []Message
+	rest := data[16:]
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("truncated OSC bundle element size")
+		}
+		size := int(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		if size < 0 || size > len(rest) {
+			return nil, fmt.Errorf("OSC bundle element exceeds packet")
+		}
+		msgs, err := Parse(rest[:size])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msgs...)
+		rest = rest[size:]
+	}
+	return out, nil
+}
+
+func parseMessage(data []byte) (Message, error) {
+	addr, rest, err := readString(data)
+	if err != nil {
+		return Message{}, fmt.Errorf("invalid OSC address: %w", err)
+	}
+	if len(addr) == 0 || addr[0] != '/' {
+		return Message{}, fmt.Errorf("invalid OSC address %q", addr)
+	}
+	msg := Message{Address: addr}
+	if len(rest) == 0 {
+		return msg, nil
+	}
+	tags, rest, err := readString(rest)
+	if err != nil {
+		return Message{}, fmt.Errorf("invalid OSC type tags: %w", err)
+	}
+	if len(tags) == 0 || tags[0] != ',' {
+		return Message{}, fmt.Errorf("invalid OSC type tags %q", tags)
+	}
+	for _, t := range tags[1:] {
+		switch t {
+		case 'i':
+			if len(rest) < 4 {
+				return Message{}, fmt.Errorf("truncated int32 argument")
+			}
+			msg.Args = append(msg.Args, int32(binary.BigEndian.Uint32(rest)))
+			rest = rest[4:]
+		case 'f':
+			if len(rest) < 4 {
+				return Message{}, fmt.Errorf("truncated float32 argument")
+			}
+			msg.Args = append(msg.Args, math.Float32frombits(binary.BigEndian.Uint32(rest)))
+			rest = rest[4:]
+		case 's':
+			var s string
+			s, rest, err = readString(rest)
+			if err != nil {
+				return Message{}, fmt.Errorf("invalid string argument: %w", err)
+			}
+			msg.Args = append(msg.Args, s)
+		case 'T':
+			msg.Args = append(msg.Args, true)
+		case 'F':
+			msg.Args = append(msg.Args, false)
+		default:
+			return Message{}, fmt.Errorf("unsupported OSC type tag %q", t)
+		}
+	}
+	return msg, nil
+}
+
+func readString(data []byte) (string, []byte, error) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", nil, fmt.Errorf("unterminated string")
+	}
+	padded := (end + 4) &^ 3
+	if padded > len(data) {
+		padded = len(data)
+	}
+	return string(data[:end]), data[padded:], nil
+}
+
+// Listen receives OSC packets on a UDP address and calls handle for every
+// message, until ctx is cancelled.
+func Listen(ctx context.Context, addr string, handle func(Message)) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for OSC on %s: %w", addr, err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		msgs, err := Parse(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			handle(m)
+		}
+	}
+}
+
+func (m Message) Float(i int) (float64, bool) {
+	if i >= len(m.Args) {
+		return 0, false
+	}
+	switch v := m.Args[i].(type) {
+	case float32:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func (m Message) String(i int) (string, bool) {
+	if i >= len(m.Args) {
+		return "", false
+	}
+	s, ok := m.Args[i].(string)
+	return s, ok
+}
@@ -0,0 +1,109 @@
+package palette
+
+import (
+	"fmt"
+	"strings"
+
+	"elano.fr/src/backend/models"
+)
+
+func Validate(p models.Palette) error {
+	if p.Name == "" {
+		return fmt.Errorf("palette name is required")
+	}
+	switch p.Type {
+	case models.PaletteColor, models.PalettePosition, models.PaletteBeam:
+	default:
+		return fmt.Errorf("palette type must be color, position or beam, got %q", p.Type)
+	}
+	if len(p.Values) == 0 {
+		return fmt.Errorf("palette must set at least one channel")
+	}
+	for name, v := range p.Values {
+		if name == "" {
+			return fmt.Errorf("palette channel name cannot be empty")
+		}
+		if v < 0 || v > 255 {
+			return fmt.Errorf("palette value for %q must be 0-255, got %d", name, v)
+		}
+	}
+	return nil
+}
+
+// ValidateRefs checks that every palette and fixture a preset refers to exists.
+func ValidateRefs(preset models.Preset, project *models.Project) error {
+	for i, ref := range preset.Palettes {
+		if find(project, ref.PaletteID) == nil {
+			return fmt.Errorf("palette reference[%d] points to unknown palette %s", i, ref.PaletteID)
+		}
+		if len(ref.FixtureIDs) == 0 {
+			return fmt.Errorf("palette reference[%d] has no fixtures", i)
+		}
+		for _, id := range ref.FixtureIDs {
+			if findFixture(project, id) == nil {
+				return fmt.Errorf("palette reference[%d] points to unknown fixture %s", i, id)
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve returns the channel values of a preset: its raw channel values,
+// overlaid with the current values of every palette it references. Palette
+// values are matched to fixture channels by name, case-insensitively.
+func Resolve(preset models.Preset, project *models.Project) map[int]byte {
+	channels := make(map[int]byte, len(preset.Channels))
+	for _, ch := range preset.Channels {
+		channels[ch.DMXAddress] = ch.Value
+	}
+	if project == nil {
+		return channels
+	}
+	for _, ref := range preset.Palettes {
+		pal := find(project, ref.PaletteID)
+		if pal == nil {
+			continue
+		}
+		for _, id := range ref.FixtureIDs {
+			f := findFixture(project, id)
+			if f == nil {
+				continue
+			}
+			for _, ch := range f.Channels {
+				for name, v := range pal.Values {
+					if strings.EqualFold(ch.Name, name) && ch.ChannelAddress >= 1 && ch.ChannelAddress <= 512 {
+						channels[ch.ChannelAddress] = byte(v)
+					}
+				}
+			}
+		}
+	}
+	return channels
+}
+
+func Uses(preset models.Preset, paletteID string) bool {
+	for _, ref := range preset.Palettes {
+		if ref.PaletteID == paletteID {
+			return true
+		}
+	}
+	return false
+}
+
+func find(project *models.Project, id string) *models.Palette {
+	for i := range project.Palettes {
+		if project.Palettes[i].ID == id {
+			return &project.Palettes[i]
+		}
+	}
+	return nil
+}
+
+func findFixture(project *models.Project, id string) *models.Fixture {
+	for i := range project.Fixtures {
+		if project.Fixtures[i].ID == id {
+			return &project.Fixtures[i]
+		}
+	}
+	return nil
+}
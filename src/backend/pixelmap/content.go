@@ -0,0 +1,259 @@
+package pixelmap
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ContentSolid    = "solid"
+	ContentGradient = "gradient"
+	ContentText     = "text"
+	ContentImage    = "image"
+)
+
+type Content struct {
+	Type   string   `json:"type"`
+	Color  string   `json:"color,omitempty"`
+	Colors []string `json:"colors,omitempty"`
+	Angle  float64  `json:"angle,omitempty"`
+	Speed  float64  `json:"speed,omitempty"`
+	Text   string   `json:"text,omitempty"`
+	File   string   `json:"file,omitempty"`
+}
+
+// Source produces the color of a cell of a w x h grid at time t since the
+// content started.
+type Source interface {
+	At(x, y, w, h int, t time.Duration) color.RGBA
+}
+
+func NewSource(c Content, mediaDir string) (Source, error) {
+	switch c.Type {
+	case ContentSolid:
+		col, err := parseColor(c.Color)
+		if err != nil {
+			return nil, err
+		}
+		return solidSource{col}, nil
+	case ContentGradient:
+		if len(c.Colors) < 2 {
+			return nil, fmt.Errorf("gradient needs at least two colors")
+		}
+		g := gradientSource{angle: c.Angle * math.Pi / 180, speed: c.Speed}
+		for _, s := range c.Colors {
+			col, err := parseColor(s)
+			if err != nil {
+				return nil, err
+			}
+			g.stops = append(g.stops, col)
+		}
+		return g, nil
+	case ContentText:
+		if c.Text == "" {
+			return nil, fmt.Errorf("text content requires text")
+		}
+		col := color.RGBA{255, 255, 255, 255}
+		if c.Color != "" {
+			var err error
+			if col, err = parseColor(c.Color); err != nil {
+				return nil, err
+			}
+		}
+		return textSource{bitmap: textBitmap(c.Text), color: col, speed: c.Speed}, nil
+	case ContentImage:
+		return loadImage(c.File, mediaDir)
+	default:
+		return nil, fmt.Errorf("unknown content type %q", c.Type)
+	}
+}
+
+func parseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("color must be #RRGGBB, got %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("color must be #RRGGBB, got %q", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+type solidSource struct{ color color.RGBA }
+
+func (s solidSource) At(_, _, _, _ int, _ time.Duration) color.RGBA { return s.color }
+
+type gradientSource struct {
+	stops []color.RGBA
+	angle float64
+	speed float64
+}
+
+func (g gradientSource) At(x, y, w, h int, t time.Duration) color.RGBA {
+	cos, sin := math.Cos(g.angle), math.Sin(g.angle)
+	u := (float64(x) + 0.5) / float64(w)
+	v := (float64(y) + 0.5) / float64(h)
+	lo := math.Min(0, cos) + math.Min(0, sin)
+	hi := math.Max(0, cos) + math.Max(0, sin)
+	p := 0.0
+	if hi > lo {
+		p = (u*cos + v*sin - lo) / (hi - lo)
+	}
+	if g.speed == 0 {
+		return interpolate(g.stops, p, false)
+	}
+	p = p + g.speed*t.Seconds()
+	return interpolate(g.stops, p-math.Floor(p), true)
+}
+
+// interpolate blends between evenly spaced stops. A cyclic gradient wraps
+// from the last stop back to the first so it can scroll seamlessly.
+func interpolate(stops []color.RGBA, p float64, cyclic bool) color.RGBA {
+	segments := len(stops) - 1
+	if cyclic {
+		segments = len(stops)
+	}
+	pos := math.Max(0, math.Min(1, p)) * float64(segments)
+	i := int(pos)
+	if i >= segments {
+		i = segments - 1
+	}
+	f := pos - float64(i)
+	a, b := stops[i], stops[(i+1)%len(stops)]
+	mix := func(x, y uint8) uint8 { return uint8(math.Round(float64(x) + (float64(y)-float64(x))*f)) }
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+type textSource struct {
+	bitmap [][]bool
+	color  color.RGBA
+	speed  float64
+}
+
+func (s textSource) At(x, y, w, h int, t time.Duration) color.RGBA {
+	row := y - (h-glyphHeight)/2
+	if row < 0 || row >= glyphHeight {
+		return color.RGBA{}
+	}
+	width := len(s.bitmap[0])
+	col := x
+	if s.speed != 0 {
+		// Scroll right to left, entering from the right edge and leaving
+		// completely before wrapping around.
+		total := width + w
+		shift := int(math.Floor(s.speed*t.Seconds())) % total
+		if shift < 0 {
+			shift += total
+		}
+		col = x + shift - w
+	}
+	if col < 0 || col >= width || !s.bitmap[row][col] {
+		return color.RGBA{}
+	}
+	return s.color
+}
+
+type imageSource struct {
+	frames []image.Image
+	delays []time.Duration
+	total  time.Duration
+}
+
+func (s *imageSource) At(x, y, w, h int, t time.Duration) color.RGBA {
+	frame := s.frames[0]
+	if len(s.frames) > 1 && s.total > 0 {
+		pos := t % s.total
+		for i, d := range s.delays {
+			if pos < d {
+				frame = s.frames[i]
+				break
+			}
+			pos -= d
+		}
+	}
+	b := frame.Bounds()
+	ix := b.Min.X + (2*x+1)*b.Dx()/(2*w)
+	iy := b.Min.Y + (2*y+1)*b.Dy()/(2*h)
+	return color.RGBAModel.Convert(frame.At(ix, iy)).(color.RGBA)
+}
+
+func loadImage(name, mediaDir string) (Source, error) {
+	if name == "" {
+		return nil, fmt.Errorf("image content requires a file")
+	}
+	clean := filepath.Clean(name)
+	if filepath.IsAbs(clean) || strings.HasPrefix(clean, "..") {
+		return nil, fmt.Errorf("image file must be relative to the media directory")
+	}
+	path := filepath.Join(mediaDir, clean)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image %q: %w", name, err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		g, err := gif.DecodeAll(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode GIF %q: %w", name, err)
+		}
+		return gifSource(g), nil
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %q: %w", name, err)
+	}
+	return &imageSource{frames: []image.Image{img}}, nil
+}
+
+func gifSource(g *gif.GIF) *imageSource {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() && len(g.Image) > 0 {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+	src := &imageSource{}
+	for i, frame := range g.Image {
+		var previous *image.RGBA
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		snapshot := image.NewRGBA(bounds)
+		draw.Draw(snapshot, bounds, canvas, bounds.Min, draw.Src)
+		src.frames = append(src.frames, snapshot)
+
+		delay := 100 * time.Millisecond
+		if i < len(g.Delay) && g.Delay[i] > 0 {
+			delay = time.Duration(g.Delay[i]) * 10 * time.Millisecond
+		}
+		src.delays = append(src.delays, delay)
+		src.total += delay
+
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+			case gif.DisposalPrevious:
+				canvas = previous
+			}
+		}
+	}
+	return src
+}
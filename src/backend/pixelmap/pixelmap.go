@@ -0,0 +1,210 @@
+package pixelmap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/models"
+)
+
+type cell struct {
+	x, y    int
+	r, g, b int
+}
+
+type Mapping struct {
+	ID     string
+	Width  int
+	Height int
+	cells  []cell
+}
+
+func Validate(pm models.PixelMap) error {
+	if pm.Name == "" {
+		return fmt.Errorf("pixel map name is required")
+	}
+	if pm.Width < 1 || pm.Width > 1024 || pm.Height < 1 || pm.Height > 1024 {
+		return fmt.Errorf("pixel map size must be between 1x1 and 1024x1024")
+	}
+	if len(pm.Cells) == 0 {
+		return fmt.Errorf("pixel map has no cells")
+	}
+	for i, c := range pm.Cells {
+		if c.X < 0 || c.X >= pm.Width || c.Y < 0 || c.Y >= pm.Height {
+			return fmt.Errorf("cell[%d] at %d,%d is outside the grid", i, c.X, c.Y)
+		}
+		for _, addr := range []int{c.Red, c.Green, c.Blue} {
+			if addr < 0 || addr > 512 {
+				return fmt.Errorf("cell[%d] has invalid DMX address %d", i, addr)
+			}
+		}
+		if c.FixtureID == "" && (c.Red == 0 || c.Green == 0 || c.Blue == 0) {
+			return fmt.Errorf("cell[%d] needs a fixture or red, green and blue addresses", i)
+		}
+	}
+	return nil
+}
+
+// Resolve turns a pixel map definition into DMX addresses. Cells that only
+// name a fixture use its red, green and blue channels.
+func Resolve(pm models.PixelMap, fixtures []models.Fixture) (*Mapping, error) {
+	if err := Validate(pm); err != nil {
+		return nil, err
+	}
+	m := &Mapping{ID: pm.ID, Width: pm.Width, Height: pm.Height}
+	for i, c := range pm.Cells {
+		r, g, b := c.Red, c.Green, c.Blue
+		if c.FixtureID != "" {
+			var fixture *models.Fixture
+			for j := range fixtures {
+				if fixtures[j].ID == c.FixtureID {
+					fixture = &fixtures[j]
+					break
+				}
+			}
+			if fixture == nil {
+				return nil, fmt.Errorf("cell[%d] references unknown fixture %s", i, c.FixtureID)
+			}
+			var err error
+			if r, g, b, err = fixtureChannels(c, *fixture); err != nil {
+				return nil, fmt.Errorf("cell[%d] %w", i, err)
+			}
+		}
+		m.cells = append(m.cells, cell{x: c.X, y: c.Y, r: r, g: g, b: b})
+	}
+	return m, nil
+}
+
+// ValidateFixtures checks that the fixtures the cells of a pixel map use have
+// the red, green and blue channels the cells do not set themselves. Cells
+// naming an unknown fixture are skipped: they are dangling references, which
+// are checked separately.
+func ValidateFixtures(pm models.PixelMap, fixtures []models.Fixture) error {
+	for i, c := range pm.Cells {
+		if c.FixtureID == "" {
+			continue
+		}
+		for _, f := range fixtures {
+			if f.ID == c.FixtureID {
+				if _, _, _, err := fixtureChannels(c, f); err != nil {
+					return fmt.Errorf("cell[%d] %w", i, err)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// fixtureChannels returns the DMX addresses of a cell using a fixture.
+func fixtureChannels(c models.PixelCell, f models.Fixture) (r, g, b int, err error) {
+	r, g, b = c.Red, c.Green, c.Blue
+	if r == 0 {
+		r = colorChannel(f, "red", "r")
+	}
+	if g == 0 {
+		g = colorChannel(f, "green", "g")
+	}
+	if b == 0 {
+		b = colorChannel(f, "blue", "b")
+	}
+	if r == 0 || g == 0 || b == 0 {
+		return 0, 0, 0, fmt.Errorf("fixture %q has no red, green and blue channels", f.Name)
+	}
+	return r, g, b, nil
+}
+
+func colorChannel(f models.Fixture, names ...string) int {
+	for _, ch := range f.Channels {
+		for _, n := range names {
+			if strings.EqualFold(ch.Name, n) {
+				return ch.ChannelAddress
+			}
+		}
+	}
+	return 0
+}
+
+type ActiveMap struct {
+	PixelMapID string  `json:"pixel_map_id"`
+	Content    Content `json:"content"`
+}
+
+type active struct {
+	mapping *Mapping
+	content Content
+	source  Source
+	start   time.Time
+}
+
+type Engine struct {
+	mu     sync.RWMutex
+	active map[string]*active
+}
+
+func NewEngine() *Engine {
+	return &Engine{active: make(map[string]*active)}
+}
+
+func (e *Engine) Start(m *Mapping, content Content, src Source, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active[m.ID] = &active{mapping: m, content: content, source: src, start: now}
+}
+
+func (e *Engine) Stop(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, ok := e.active[id]
+	delete(e.active, id)
+	return ok
+}
+
+func (e *Engine) StopAll() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids := make([]string, 0, len(e.active))
+	for id := range e.active {
+		ids = append(ids, id)
+	}
+	e.active = make(map[string]*active)
+	sort.Strings(ids)
+	return ids
+}
+
+func (e *Engine) Active() []ActiveMap {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]ActiveMap, 0, len(e.active))
+	for id, a := range e.active {
+		out = append(out, ActiveMap{PixelMapID: id, Content: a.content})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PixelMapID < out[j].PixelMapID })
+	return out
+}
+
+// Apply renders every active pixel map into channels, which is indexed by DMX
+// address minus one. Mapped channels are overwritten.
+func (e *Engine) Apply(now time.Time, channels []byte) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, a := range e.active {
+		t := now.Sub(a.start)
+		m := a.mapping
+		for _, c := range m.cells {
+			col := a.source.At(c.x, c.y, m.Width, m.Height, t)
+			setChannel(channels, c.r, col.R)
+			setChannel(channels, c.g, col.G)
+			setChannel(channels, c.b, col.B)
+		}
+	}
+}
+
+func setChannel(channels []byte, addr int, v byte) {
+	if addr >= 1 && addr <= len(channels) {
+		channels[addr-1] = v
+	}
+}
@@ -0,0 +1,316 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/models"
+)
+
+const (
+	EventCron  = "cron"
+	EventStart = "start"
+	EventEnd   = "end"
+)
+
+type Firing struct {
+	ScheduleID string                `json:"schedule_id"`
+	Time       time.Time             `json:"time"`
+	Event      string                `json:"event"`
+	Action     models.ScheduleAction `json:"action"`
+}
+
+type Scheduler struct {
+	project func() *models.Project
+	trigger func(models.ScheduleAction) error
+
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+func New(project func() *models.Project, trigger func(models.ScheduleAction) error) *Scheduler {
+	return &Scheduler{project: project, trigger: trigger}
+}
+
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.stop = make(chan struct{})
+	go s.run(s.stop)
+}
+
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+func (s *Scheduler) run(stop chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.tick(last, now)
+			last = now
+		}
+	}
+}
+
+func (s *Scheduler) tick(from, to time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in scheduler: %v", r)
+		}
+	}()
+	project := s.project()
+	if project == nil {
+		return
+	}
+	for _, sched := range project.Schedules {
+		if !sched.Enabled {
+			continue
+		}
+		// Only the latest firing inside the window is triggered, so a clock
+		// jump or a stalled process does not replay a backlog of actions.
+		var due *Firing
+		after := from
+		for {
+			f, ok, err := nextFiring(sched, project.Location, after)
+			if err != nil || !ok || f.Time.After(to) {
+				break
+			}
+			due = &f
+			after = f.Time
+		}
+		if due == nil {
+			continue
+		}
+		log.Printf("Schedule %q firing %s action %s", sched.Name, due.Event, due.Action.Type)
+		if err := s.trigger(due.Action); err != nil {
+			log.Printf("Schedule %q action %s failed: %v", sched.Name, due.Action.Type, err)
+		}
+	}
+}
+
+func Validate(s models.Schedule) error {
+	if s.Name == "" {
+		return fmt.Errorf("schedule name is required")
+	}
+	if s.Cron != "" && s.Start != nil {
+		return fmt.Errorf("schedule must use either a cron expression or a start time, not both")
+	}
+	if s.Cron == "" && s.Start == nil {
+		return fmt.Errorf("schedule requires a cron expression or a start time")
+	}
+	if s.Cron != "" {
+		if _, err := ParseCron(s.Cron); err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+		if s.End != nil || s.EndAction != nil {
+			return fmt.Errorf("cron schedules cannot have an end time")
+		}
+	}
+	if s.End != nil && s.Start == nil {
+		return fmt.Errorf("end time requires a start time")
+	}
+	if s.EndAction != nil && s.End == nil {
+		return fmt.Errorf("end action requires an end time")
+	}
+	for _, d := range s.Days {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("day %d out of range (0=Sunday to 6=Saturday)", d)
+		}
+	}
+	if s.Start != nil {
+		if err := validateTime(*s.Start); err != nil {
+			return fmt.Errorf("start: %w", err)
+		}
+	}
+	if s.End != nil {
+		if err := validateTime(*s.End); err != nil {
+			return fmt.Errorf("end: %w", err)
+		}
+	}
+	if err := ValidateAction(s.Action); err != nil {
+		return fmt.Errorf("action: %w", err)
+	}
+	if s.EndAction != nil {
+		if err := ValidateAction(*s.EndAction); err != nil {
+			return fmt.Errorf("end action: %w", err)
+		}
+	}
+	return nil
+}
+
+func ValidateAction(a models.ScheduleAction) error {
+	switch a.Type {
+	case models.ActionRunShow:
+		if a.ShowID == "" {
+			return fmt.Errorf("show ID is required for %s", a.Type)
+		}
+	case models.ActionApplyPreset:
+		if a.PresetID == "" {
+			return fmt.Errorf("preset ID is required for %s", a.Type)
+		}
+	case models.ActionBlackout:
+	default:
+		return fmt.Errorf("unknown action type %q", a.Type)
+	}
+	return nil
+}
+
+func validateTime(t models.ScheduleTime) error {
+	if (t.Time == "") == (t.Sun == "") {
+		return fmt.Errorf("exactly one of time or sun is required")
+	}
+	if t.Time != "" {
+		if _, _, err := parseClock(t.Time); err != nil {
+			return err
+		}
+	}
+	if t.Sun != "" && t.Sun != "sunrise" && t.Sun != "sunset" {
+		return fmt.Errorf("sun must be sunrise or sunset, got %q", t.Sun)
+	}
+	if t.OffsetMinutes < -720 || t.OffsetMinutes > 720 {
+		return fmt.Errorf("offset must be within 12 hours")
+	}
+	return nil
+}
+
+func parseClock(s string) (int, int, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("time must be HH:MM, got %q", s)
+	}
+	h, err1 := strconv.Atoi(parts[0])
+	m, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, 0, fmt.Errorf("time must be HH:MM, got %q", s)
+	}
+	return h, m, nil
+}
+
+// NextFirings previews the next count firings of a schedule after the given
+// time, regardless of whether it is enabled.
+func NextFirings(s models.Schedule, loc *models.Location, after time.Time, count int) ([]Firing, error) {
+	var out []Firing
+	for len(out) < count {
+		f, ok, err := nextFiring(s, loc, after)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		out = append(out, f)
+		after = f.Time
+	}
+	return out, nil
+}
+
+func nextFiring(s models.Schedule, loc *models.Location, after time.Time) (Firing, bool, error) {
+	if s.Cron != "" {
+		spec, err := ParseCron(s.Cron)
+		if err != nil {
+			return Firing{}, false, err
+		}
+		t := spec.Next(after)
+		if t.IsZero() {
+			return Firing{}, false, nil
+		}
+		return Firing{ScheduleID: s.ID, Time: t, Event: EventCron, Action: s.Action}, true, nil
+	}
+	if s.Start == nil {
+		return Firing{}, false, nil
+	}
+
+	endAction := models.ScheduleAction{Type: models.ActionBlackout}
+	if s.EndAction != nil {
+		endAction = *s.EndAction
+	}
+
+	var candidates []Firing
+	// Look back a day so a window that started yesterday can still end today.
+	day := time.Date(after.Year(), after.Month(), after.Day()-1, 0, 0, 0, 0, after.Location())
+	for i := 0; i < 370 && len(candidates) == 0; i++ {
+		d := day.AddDate(0, 0, i)
+		if !dayEnabled(s.Days, d.Weekday()) {
+			continue
+		}
+		start, ok, err := resolveTime(*s.Start, d, loc)
+		if err != nil {
+			return Firing{}, false, err
+		}
+		if !ok {
+			continue
+		}
+		if start.After(after) {
+			candidates = append(candidates, Firing{ScheduleID: s.ID, Time: start, Event: EventStart, Action: s.Action})
+		}
+		if s.End != nil {
+			end, ok, err := resolveTime(*s.End, d, loc)
+			if err != nil {
+				return Firing{}, false, err
+			}
+			if ok {
+				if !end.After(start) {
+					end = end.Add(24 * time.Hour)
+				}
+				if end.After(after) {
+					candidates = append(candidates, Firing{ScheduleID: s.ID, Time: end, Event: EventEnd, Action: endAction})
+				}
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return Firing{}, false, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Time.Before(candidates[j].Time) })
+	return candidates[0], true, nil
+}
+
+func dayEnabled(days []int, wd time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if time.Weekday(d) == wd {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveTime(t models.ScheduleTime, day time.Time, loc *models.Location) (time.Time, bool, error) {
+	offset := time.Duration(t.OffsetMinutes) * time.Minute
+	if t.Time != "" {
+		h, m, err := parseClock(t.Time)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		return time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location()).Add(offset), true, nil
+	}
+	if loc == nil {
+		return time.Time{}, false, fmt.Errorf("project location is required for sunrise/sunset schedules")
+	}
+	at, ok := SunEvent(day, loc.Latitude, loc.Longitude, t.Sun == "sunrise")
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	return at.Truncate(time.Minute).Add(offset), true, nil
+}
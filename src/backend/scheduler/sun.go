@@ -0,0 +1,71 @@
+package scheduler
+
+import (
+	"math"
+	"time"
+)
+
+const sunZenith = 90.833
+
+// SunEvent returns the sunrise (rising) or sunset time at the given location on
+// the calendar day of date, expressed in date's location. The second result is
+// false when the sun does not rise or set that day (polar day or night).
+func SunEvent(date time.Time, latitude, longitude float64, rising bool) (time.Time, bool) {
+	rad := math.Pi / 180
+	n := float64(date.YearDay())
+	lngHour := longitude / 15
+
+	var t float64
+	if rising {
+		t = n + (6-lngHour)/24
+	} else {
+		t = n + (18-lngHour)/24
+	}
+
+	m := 0.9856*t - 3.289
+	l := normalizeDegrees(m + 1.916*math.Sin(m*rad) + 0.020*math.Sin(2*m*rad) + 282.634)
+
+	ra := normalizeDegrees(math.Atan(0.91764*math.Tan(l*rad)) / rad)
+	ra += math.Floor(l/90)*90 - math.Floor(ra/90)*90
+	ra /= 15
+
+	sinDec := 0.39782 * math.Sin(l*rad)
+	cosDec := math.Cos(math.Asin(sinDec))
+	cosH := (math.Cos(sunZenith*rad) - sinDec*math.Sin(latitude*rad)) / (cosDec * math.Cos(latitude*rad))
+	if cosH > 1 || cosH < -1 {
+		return time.Time{}, false
+	}
+
+	var h float64
+	if rising {
+		h = 360 - math.Acos(cosH)/rad
+	} else {
+		h = math.Acos(cosH) / rad
+	}
+	h /= 15
+
+	localT := h + ra - 0.06571*t - 6.622
+	ut := math.Mod(localT-lngHour+48, 24)
+
+	y, mo, d := date.Date()
+	result := time.Date(y, mo, d, 0, 0, 0, 0, time.UTC).Add(time.Duration(ut * float64(time.Hour))).In(date.Location())
+
+	// The UTC calculation can land on the neighbouring local day.
+	ry, rm, rd := result.Date()
+	local := time.Date(y, mo, d, 0, 0, 0, 0, date.Location())
+	resultDay := time.Date(ry, rm, rd, 0, 0, 0, 0, date.Location())
+	if resultDay.Before(local) {
+		result = result.Add(24 * time.Hour)
+	} else if resultDay.After(local) {
+		result = result.Add(-24 * time.Hour)
+	}
+	return result, true
+}
+
+func normalizeDegrees(d float64) float64 {
+	d = math.Mod(d, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
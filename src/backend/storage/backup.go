@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"elano.fr/src/backend/models"
+)
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+const (
+	BackupManual  = "backup"
+	BackupDeleted = "deleted"
+	BackupUpgrade = "schema_upgrade"
+)
+
+// BackupPolicy decides when a store backs up the project before saving it
+// and which backups it keeps.
+type BackupPolicy struct {
+	// Interval backs up before a save when the last backup is older than
+	// this. Zero disables time-based backups.
+	Interval time.Duration `json:"interval"`
+	// EverySaves backs up before every Nth save. Zero disables it.
+	EverySaves int `json:"every_saves"`
+	// Keep is the number of regular backups kept; deletion and upgrade
+	// backups are not counted.
+	Keep int `json:"keep"`
+	// MaxAge prunes backups of any kind older than this. Zero keeps them.
+	MaxAge time.Duration `json:"max_age"`
+}
+
+func DefaultBackupPolicy() BackupPolicy {
+	return BackupPolicy{Interval: 5 * time.Minute, Keep: 10}
+}
+
+type BackupInfo struct {
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	Time        time.Time `json:"time"`
+	Size        int64     `json:"size"`
+	ProjectID   string    `json:"project_id,omitempty"`
+	ProjectName string    `json:"project_name,omitempty"`
+	Revision    int64     `json:"revision,omitempty"` // SQLite backups only
+	Error       string    `json:"error,omitempty"`
+}
+
+// BackupManager is implemented by stores that keep backups of their project.
+type BackupManager interface {
+	Backups() ([]BackupInfo, error)
+	LoadBackup(name string) (*models.Project, error)
+	BackupFile(name string) (string, error)
+	BackupPolicy() BackupPolicy
+	SetBackupPolicy(BackupPolicy)
+}
+
+// ProjectBackups is a project store whose backups can be browsed and
+// restored.
+type ProjectBackups interface {
+	ProjectStore
+	BackupManager
+}
+
+// backupSet manages the backups directory of a store. Its methods are called
+// with the store locked.
+type backupSet struct {
+	dir    string
+	ext    string
+	policy BackupPolicy
+	last   time.Time
+	saves  int
+}
+
+func newBackupSet(dir, ext string) (*backupSet, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	b := &backupSet{dir: dir, ext: ext, policy: DefaultBackupPolicy()}
+	if infos, err := b.files(); err == nil {
+		for _, info := range infos {
+			if info.Kind == BackupManual && info.Time.After(b.last) {
+				b.last = info.Time
+			}
+		}
+	}
+	return b, nil
+}
+
+// due counts a save and reports whether the policy asks for a backup first.
+func (b *backupSet) due(now time.Time) bool {
+	b.saves++
+	if b.policy.EverySaves > 0 && b.saves >= b.policy.EverySaves {
+		return true
+	}
+	return b.policy.Interval > 0 && now.Sub(b.last) >= b.policy.Interval
+}
+
+// path returns the file a new backup of the given kind is written to,
+// numbering it when another backup was taken in the same second.
+func (b *backupSet) path(kind string) string {
+	base := fmt.Sprintf("%s_%s", kind, time.Now().Format("20060102_150405"))
+	path := filepath.Join(b.dir, base+b.ext)
+	for n := 2; fileExists(path); n++ {
+		path = filepath.Join(b.dir, fmt.Sprintf("%s_%d%s", base, n, b.ext))
+	}
+	return path
+}
+
+// taken records a successful backup and prunes old ones in the background.
+func (b *backupSet) taken(kind string) {
+	if kind == BackupManual {
+		b.last = time.Now()
+		b.saves = 0
+	}
+	policy := b.policy
+	go b.prune(policy)
+}
+
+func (b *backupSet) prune(policy BackupPolicy) {
+	infos, err := b.files()
+	if err != nil {
+		return
+	}
+	regular := 0
+	for _, info := range infos {
+		expired := policy.MaxAge > 0 && time.Since(info.Time) > policy.MaxAge
+		if info.Kind == BackupManual {
+			regular++
+			expired = expired || (policy.Keep > 0 && regular > policy.Keep)
+		}
+		if expired {
+			os.Remove(filepath.Join(b.dir, info.Name))
+		}
+	}
+}
+
+// files lists the backups on disk, newest first, without opening them.
+func (b *backupSet) files() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []BackupInfo{}, nil
+		}
+		return nil, fmt.Errorf("failed to read backups: %w", err)
+	}
+	infos := []BackupInfo{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".db") {
+			continue
+		}
+		st, err := e.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, BackupInfo{Name: e.Name(), Kind: backupKind(e.Name()), Time: st.ModTime(), Size: st.Size()})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Time.After(infos[j].Time) })
+	return infos, nil
+}
+
+func backupKind(name string) string {
+	switch {
+	case strings.HasPrefix(name, BackupManual+"_"):
+		return BackupManual
+	case strings.HasPrefix(name, BackupDeleted+"_"):
+		return BackupDeleted
+	case strings.Contains(name, "_schema_v"):
+		return BackupUpgrade
+	}
+	return ""
+}
+
+// list is files with the project name and revision read from each backup.
+func (b *backupSet) list() ([]BackupInfo, error) {
+	infos, err := b.files()
+	if err != nil {
+		return nil, err
+	}
+	for i := range infos {
+		project, err := b.load(infos[i].Name)
+		if err != nil {
+			infos[i].Error = err.Error()
+			continue
+		}
+		infos[i].ProjectID = project.ID
+		infos[i].ProjectName = project.Name
+		infos[i].Revision = project.Revision
+	}
+	return infos, nil
+}
+
+func (b *backupSet) file(name string) (string, error) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", ErrBackupNotFound
+	}
+	path := filepath.Join(b.dir, name)
+	if st, err := os.Stat(path); err != nil || st.IsDir() {
+		return "", ErrBackupNotFound
+	}
+	return path, nil
+}
+
+// load reads a backup without modifying it, whatever its format.
+func (b *backupSet) load(name string) (*models.Project, error) {
+	path, err := b.file(name)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".db" {
+		return loadSQLiteProject(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup %q: %w", name, err)
+	}
+	defer f.Close()
+	return DecodeProjectYAML(f)
+}
@@ -0,0 +1,229 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"elano.fr/src/backend/models"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Change records how one entity of a project changed between two versions.
+// Before is empty for a creation and After is empty for a deletion; Index is
+// the position of the entity in its list before the change.
+type Change struct {
+	Entity string          `json:"entity"`
+	ID     string          `json:"id"`
+	Name   string          `json:"name,omitempty"`
+	Op     string          `json:"op"`
+	Index  int             `json:"index"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+type projectSettings struct {
+	Name         string           `json:"name"`
+	USBInterface string           `json:"usb_interface"`
+	Location     *models.Location `json:"location,omitempty"`
+}
+
+type entityKind struct {
+	name  string
+	items func(p *models.Project) ([]string, []json.RawMessage)
+	set   func(p *models.Project, id string, index int, raw json.RawMessage) error
+}
+
+func collection[T any](name string, list func(*models.Project) *[]T, id func(T) string) entityKind {
+	return entityKind{
+		name: name,
+		items: func(p *models.Project) ([]string, []json.RawMessage) {
+			items := *list(p)
+			ids := make([]string, len(items))
+			raws := make([]json.RawMessage, len(items))
+			for i, item := range items {
+				ids[i] = id(item)
+				raws[i], _ = json.Marshal(item)
+			}
+			return ids, raws
+		},
+		set: func(p *models.Project, entityID string, index int, raw json.RawMessage) error {
+			items := list(p)
+			pos := -1
+			for i, item := range *items {
+				if id(item) == entityID {
+					pos = i
+					break
+				}
+			}
+			if raw == nil {
+				if pos >= 0 {
+					*items = append((*items)[:pos], (*items)[pos+1:]...)
+				}
+				return nil
+			}
+			var item T
+			if err := json.Unmarshal(raw, &item); err != nil {
+				return fmt.Errorf("failed to decode %s %s: %w", name, entityID, err)
+			}
+			if pos >= 0 {
+				(*items)[pos] = item
+				return nil
+			}
+			index = max(0, min(index, len(*items)))
+			*items = append((*items)[:index], append([]T{item}, (*items)[index:]...)...)
+			return nil
+		},
+	}
+}
+
+var entityKinds = []entityKind{
+	{
+		name: "project",
+		items: func(p *models.Project) ([]string, []json.RawMessage) {
+			raw, _ := json.Marshal(projectSettings{Name: p.Name, USBInterface: p.USBInterface, Location: p.Location})
+			return []string{p.ID}, []json.RawMessage{raw}
+		},
+		set: func(p *models.Project, _ string, _ int, raw json.RawMessage) error {
+			if raw == nil {
+				return nil
+			}
+			var s projectSettings
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("failed to decode project settings: %w", err)
+			}
+			p.Name, p.USBInterface, p.Location = s.Name, s.USBInterface, s.Location
+			return nil
+		},
+	},
+	collection("fixture", func(p *models.Project) *[]models.Fixture { return &p.Fixtures }, func(f models.Fixture) string { return f.ID }),
+	collection("palette", func(p *models.Project) *[]models.Palette { return &p.Palettes }, func(p models.Palette) string { return p.ID }),
+	collection("preset", func(p *models.Project) *[]models.Preset { return &p.Presets }, func(p models.Preset) string { return p.ID }),
+	collection("show", func(p *models.Project) *[]models.Show { return &p.Shows }, func(s models.Show) string { return s.ID }),
+	collection("schedule", func(p *models.Project) *[]models.Schedule { return &p.Schedules }, func(s models.Schedule) string { return s.ID }),
+	collection("pixel_map", func(p *models.Project) *[]models.PixelMap { return &p.PixelMaps }, func(m models.PixelMap) string { return m.ID }),
+}
+
+// entityState returns the state of an entity of p as recorded in a Change,
+// or nil when p does not have it.
+func entityState(p *models.Project, entity, id string) (json.RawMessage, error) {
+	for _, kind := range entityKinds {
+		if kind.name != entity {
+			continue
+		}
+		ids, raws := kind.items(p)
+		if kind.name == "project" {
+			return raws[0], nil
+		}
+		for i := range ids {
+			if ids[i] == id {
+				return raws[i], nil
+			}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown entity type %q", entity)
+}
+
+// sameState reports whether two entity states hold the same values.
+func sameState(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// DiffProjects lists the entities created, updated or deleted between two
+// versions of a project. Either version may be nil.
+func DiffProjects(before, after *models.Project) []Change {
+	var changes []Change
+	for _, kind := range entityKinds {
+		var beforeIDs, afterIDs []string
+		var beforeRaws, afterRaws []json.RawMessage
+		if before != nil {
+			beforeIDs, beforeRaws = kind.items(before)
+		}
+		if after != nil {
+			afterIDs, afterRaws = kind.items(after)
+		}
+		if kind.name == "project" && before != nil && after != nil {
+			// The project keeps its identity even if its ID is rewritten.
+			afterIDs = beforeIDs
+		}
+		afterIndex := make(map[string]int, len(afterIDs))
+		for i, id := range afterIDs {
+			afterIndex[id] = i
+		}
+		beforeIndex := make(map[string]int, len(beforeIDs))
+		for i, id := range beforeIDs {
+			beforeIndex[id] = i
+			j, ok := afterIndex[id]
+			if !ok {
+				changes = append(changes, Change{Entity: kind.name, ID: id, Name: entityName(beforeRaws[i]), Op: OpDelete, Index: i, Before: beforeRaws[i]})
+				continue
+			}
+			if !bytes.Equal(beforeRaws[i], afterRaws[j]) {
+				changes = append(changes, Change{Entity: kind.name, ID: id, Name: entityName(afterRaws[j]), Op: OpUpdate, Index: i, Before: beforeRaws[i], After: afterRaws[j]})
+			}
+		}
+		for j, id := range afterIDs {
+			if _, ok := beforeIndex[id]; !ok {
+				changes = append(changes, Change{Entity: kind.name, ID: id, Name: entityName(afterRaws[j]), Op: OpCreate, Index: j, After: afterRaws[j]})
+			}
+		}
+	}
+	return changes
+}
+
+// ApplyChanges returns a copy of p with the changes applied, or reverted when
+// forward is false.
+func ApplyChanges(p *models.Project, changes []Change, forward bool) (*models.Project, error) {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var out models.Project
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		c := changes[i]
+		state := c.After
+		if !forward {
+			c = changes[len(changes)-1-i]
+			state = c.Before
+		}
+		applied := false
+		for _, kind := range entityKinds {
+			if kind.name == c.Entity {
+				if err := kind.set(&out, c.ID, c.Index, state); err != nil {
+					return nil, err
+				}
+				applied = true
+				break
+			}
+		}
+		if !applied {
+			return nil, fmt.Errorf("unknown entity type %q", c.Entity)
+		}
+	}
+	return &out, nil
+}
+
+func entityName(raw json.RawMessage) string {
+	var named struct {
+		Name string `json:"name"`
+	}
+	json.Unmarshal(raw, &named)
+	return named.Name
+}
@@ -0,0 +1,632 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/models"
+	"gopkg.in/yaml.v3"
+)
+
+const dirFileHeader = "# DMX Controller Project Configuration\n# Generated by elano.fr/backend\n\n"
+
+// dirEntityKinds are the entities stored one file per entity, by their key in
+// project.yaml, which is also the name of their directory.
+var dirEntityKinds = []string{"fixtures", "presets", "shows"}
+
+// DirStore stores a project as a directory meant to be kept in version
+// control. project.yaml holds the project settings, schedules, palettes and
+// pixel maps, and lists the IDs of the fixtures, presets and shows in order.
+// Each fixture, preset and show is a file of its own named after its ID in
+// fixtures/, presets/ and shows/. Fields are always written in the same order
+// so a change only touches the lines it is about.
+type DirStore struct {
+	mu      sync.RWMutex
+	project *models.Project
+	dir     string
+	backups *backupSet
+	listeners
+	disk     treeState
+	rejected [sha256.Size]byte
+}
+
+// IsProjectDirectory reports whether dir holds a project in the directory
+// layout rather than a single project.yaml.
+func IsProjectDirectory(dir string) bool {
+	for _, kind := range dirEntityKinds {
+		if st, err := os.Stat(filepath.Join(dir, kind)); err == nil && st.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+func NewDirStore(dir string) (*DirStore, error) {
+	project, err := loadProjectDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	backups, err := newBackupSet(filepath.Join(dir, "backups"), ".yaml")
+	if err != nil {
+		return nil, err
+	}
+	disk, err := readTreeState(dir)
+	if err != nil {
+		return nil, err
+	}
+	warnDanglingReferences(dir, project)
+	return &DirStore{project: project, dir: dir, backups: backups, disk: disk}, nil
+}
+
+// MigrateYAMLToDirectory converts the single-file project at path into the
+// directory layout, in the directory of the file. The original file is kept
+// as project.yaml.migrated.
+func MigrateYAMLToDirectory(path string) error {
+	project, err := LoadProjectFromFile(path)
+	if err != nil {
+		return err
+	}
+	if err := validateProject(project); err != nil {
+		return fmt.Errorf("project validation failed: %w", err)
+	}
+	if err := checkFileNames(project); err != nil {
+		return err
+	}
+	if err := os.Rename(path, path+".migrated"); err != nil {
+		return fmt.Errorf("failed to set aside migrated project file: %w", err)
+	}
+	dir := filepath.Dir(path)
+	if err := writeProjectDirectory(dir, project, nil); err != nil {
+		for _, kind := range dirEntityKinds {
+			os.RemoveAll(filepath.Join(dir, kind))
+		}
+		os.Rename(path+".migrated", path)
+		return err
+	}
+	return nil
+}
+
+// loadProjectDirectory assembles the files of a project directory into one
+// document, then decodes and validates it like a project file. Entity files
+// missing from the lists of project.yaml, as left by a merge, are appended in
+// file name order.
+func loadProjectDirectory(dir string) (*models.Project, error) {
+	path := filepath.Join(dir, workspaceProjectFile)
+	doc, err := readYAMLMapping(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, kind := range dirEntityKinds {
+		list := mappingValue(doc, kind)
+		if list == nil {
+			list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: kind}, list)
+		}
+		if err := loadEntityFiles(dir, kind, list); err != nil {
+			return nil, err
+		}
+	}
+
+	assembled, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assemble project: %w", err)
+	}
+	if len(assembled) > maxProjectFileSize {
+		return nil, fmt.Errorf("project too large: %d bytes (max %d)", len(assembled), maxProjectFileSize)
+	}
+	upgraded, _, err := upgradeYAML(assembled)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", dir, err)
+	}
+	project, err := decodeProjectYAML(upgraded)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", dir, err)
+	}
+	return project, nil
+}
+
+// readYAMLMapping reads a YAML file whose document is a mapping. Nodes are
+// kept as written so scalars round-trip exactly.
+func readYAMLMapping(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%q: failed to decode YAML: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%q: expected a mapping", path)
+	}
+	return doc.Content[0], nil
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// loadEntityFiles replaces the list of IDs of one kind of entity with the
+// content of their files.
+func loadEntityFiles(dir, kind string, list *yaml.Node) error {
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		list.Kind, list.Tag, list.Value = yaml.SequenceNode, "!!seq", ""
+	}
+	if list.Kind != yaml.SequenceNode {
+		return fmt.Errorf("project.yaml: %s must list IDs", kind)
+	}
+	var ids []string
+	listed := map[string]bool{}
+	for _, item := range list.Content {
+		if item.Kind != yaml.ScalarNode {
+			return fmt.Errorf("project.yaml: %s must list IDs", kind)
+		}
+		if item.Value != filepath.Base(item.Value) || strings.HasPrefix(item.Value, ".") {
+			return fmt.Errorf("project.yaml: %s ID %q cannot be used as a file name", kind, item.Value)
+		}
+		ids = append(ids, item.Value)
+		listed[item.Value] = true
+	}
+
+	names, err := entityFiles(filepath.Join(dir, kind))
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if id := strings.TrimSuffix(name, ".yaml"); !listed[id] {
+			ids = append(ids, id)
+			listed[id] = true
+		}
+	}
+
+	list.Content = nil
+	for _, id := range ids {
+		path := filepath.Join(dir, kind, id+".yaml")
+		if !fileExists(path) {
+			return fmt.Errorf("project.yaml lists %s %s but %q is missing", kind, id, path)
+		}
+		entity, err := readYAMLMapping(path)
+		if err != nil {
+			return err
+		}
+		if value := mappingValue(entity, "id"); value == nil || value.Value != id {
+			return fmt.Errorf("%q: id does not match the file name", path)
+		}
+		list.Content = append(list.Content, entity)
+	}
+	return nil
+}
+
+// entityFiles lists the YAML files of an entity directory, sorted.
+func entityFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %q: %w", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".yaml" && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// checkFileNames rejects entity IDs that cannot be used as file names.
+func checkFileNames(p *models.Project) error {
+	check := func(kind, id string) error {
+		if id != filepath.Base(id) || strings.HasPrefix(id, ".") || strings.ContainsAny(id, `/\`) {
+			return fmt.Errorf("%s ID %q cannot be used as a file name", kind, id)
+		}
+		return nil
+	}
+	for _, f := range p.Fixtures {
+		if err := check("fixture", f.ID); err != nil {
+			return err
+		}
+	}
+	for _, pr := range p.Presets {
+		if err := check("preset", pr.ID); err != nil {
+			return err
+		}
+	}
+	for _, s := range p.Shows {
+		if err := check("show", s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProjectDirectory writes p into dir. Only the entity files that differ
+// from before are rewritten, and the files of removed entities are deleted.
+// New and changed files are written before project.yaml and removed files are
+// deleted after it, so an interrupted save never lists an entity whose file is
+// missing.
+func writeProjectDirectory(dir string, p, before *models.Project) error {
+	entities := map[string][]entityFile{}
+	previous := map[string]map[string]any{}
+	for _, f := range p.Fixtures {
+		entities["fixtures"] = append(entities["fixtures"], entityFile{f.ID, f})
+	}
+	for _, pr := range p.Presets {
+		entities["presets"] = append(entities["presets"], entityFile{pr.ID, pr})
+	}
+	for _, s := range p.Shows {
+		entities["shows"] = append(entities["shows"], entityFile{s.ID, s})
+	}
+	if before != nil {
+		previous["fixtures"] = map[string]any{}
+		previous["presets"] = map[string]any{}
+		previous["shows"] = map[string]any{}
+		for _, f := range before.Fixtures {
+			previous["fixtures"][f.ID] = f
+		}
+		for _, pr := range before.Presets {
+			previous["presets"][pr.ID] = pr
+		}
+		for _, s := range before.Shows {
+			previous["shows"][s.ID] = s
+		}
+	}
+
+	var stale []string
+	for _, kind := range dirEntityKinds {
+		kindDir := filepath.Join(dir, kind)
+		if err := os.MkdirAll(kindDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory %q: %w", kindDir, err)
+		}
+		keep := map[string]bool{}
+		for _, e := range entities[kind] {
+			name := e.id + ".yaml"
+			keep[name] = true
+			if old, ok := previous[kind][e.id]; ok && reflect.DeepEqual(old, e.value) && fileExists(filepath.Join(kindDir, name)) {
+				continue
+			}
+			if err := writeYAMLFile(filepath.Join(kindDir, name), "", e.value); err != nil {
+				return err
+			}
+		}
+		names, err := entityFiles(kindDir)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if !keep[name] {
+				stale = append(stale, filepath.Join(kindDir, name))
+			}
+		}
+	}
+
+	p.SchemaVersion = CurrentSchemaVersion
+	var node yaml.Node
+	if err := node.Encode(p); err != nil {
+		return fmt.Errorf("failed to encode project: %w", err)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		kind := node.Content[i].Value
+		if !slices.Contains(dirEntityKinds, kind) {
+			continue
+		}
+		list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, e := range entities[kind] {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: e.id})
+		}
+		node.Content[i+1] = list
+	}
+	if err := writeYAMLFile(filepath.Join(dir, workspaceProjectFile), dirFileHeader, &node); err != nil {
+		return err
+	}
+
+	// The save is complete once project.yaml is in place. A file left behind
+	// would come back as an unlisted entity on the next load, so failing to
+	// remove it is only reported.
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			fmt.Printf("Warning: failed to remove %q: %v\n", path, err)
+		}
+	}
+	return nil
+}
+
+type entityFile struct {
+	id    string
+	value any
+}
+
+func writeYAMLFile(path, header string, v any) error {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %q: %w", path, err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode %q: %w", path, err)
+	}
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write %q: %w", path, err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to write %q: %w", path, err)
+	}
+	return nil
+}
+
+// treeState identifies the content of the files of a project directory:
+// stamp covers their names, sizes and modification times, hash their content.
+type treeState struct {
+	stamp [sha256.Size]byte
+	hash  [sha256.Size]byte
+}
+
+func projectDirectoryFiles(dir string) ([]string, error) {
+	files := []string{workspaceProjectFile}
+	for _, kind := range dirEntityKinds {
+		names, err := entityFiles(filepath.Join(dir, kind))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			files = append(files, filepath.Join(kind, name))
+		}
+	}
+	return files, nil
+}
+
+func treeStamp(dir string, files []string) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	for _, name := range files {
+		st, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return [sha256.Size]byte{}, err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", name, st.Size(), st.ModTime().UnixNano())
+	}
+	return [sha256.Size]byte(h.Sum(nil)), nil
+}
+
+func readTreeState(dir string) (treeState, error) {
+	files, err := projectDirectoryFiles(dir)
+	if err != nil {
+		return treeState{}, err
+	}
+	stamp, err := treeStamp(dir, files)
+	if err != nil {
+		return treeState{}, fmt.Errorf("failed to read project directory: %w", err)
+	}
+	h := sha256.New()
+	for _, name := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return treeState{}, fmt.Errorf("failed to read project directory: %w", err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", name, len(data))
+		h.Write(data)
+	}
+	return treeState{stamp: stamp, hash: [sha256.Size]byte(h.Sum(nil))}, nil
+}
+
+// changed is fileState.changed for a project directory. A directory without
+// project.yaml has not changed, so that saving recreates it.
+func (t treeState) changed(dir string) (bool, treeState, error) {
+	if !fileExists(filepath.Join(dir, workspaceProjectFile)) {
+		return false, t, nil
+	}
+	files, err := projectDirectoryFiles(dir)
+	if err != nil {
+		return false, t, err
+	}
+	if stamp, err := treeStamp(dir, files); err == nil && stamp == t.stamp {
+		return false, t, nil
+	}
+	state, err := readTreeState(dir)
+	if err != nil {
+		return false, t, err
+	}
+	return state.hash != t.hash, state, nil
+}
+
+func (s *DirStore) Get() *models.Project {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.project == nil {
+		return nil
+	}
+	return copyProject(s.project)
+}
+
+func (s *DirStore) Save(p *models.Project) error {
+	if p == nil {
+		return fmt.Errorf("cannot save nil project")
+	}
+
+	defer s.flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.project != nil && p.Revision != s.project.Revision {
+		return fmt.Errorf("%w: expected revision %d, current is %d", ErrRevisionConflict, p.Revision, s.project.Revision)
+	}
+
+	if err := validateProject(p); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProject, err)
+	}
+	if err := checkReferences(s.project, p); err != nil {
+		return fmt.Errorf("project validation failed: %w", err)
+	}
+	if err := checkFileNames(p); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProject, err)
+	}
+
+	if changed, _, err := s.disk.changed(s.dir); err != nil {
+		return fmt.Errorf("failed to check project directory: %w", err)
+	} else if changed {
+		return fmt.Errorf("%w: reload it before saving", ErrModifiedOnDisk)
+	}
+
+	if s.project != nil && s.backups.due(time.Now()) {
+		if err := s.createBackup(BackupManual); err != nil {
+			fmt.Printf("Warning: failed to create backup: %v\n", err)
+		}
+	}
+
+	before := s.project
+	p.Revision++
+	if err := writeProjectDirectory(s.dir, p, before); err != nil {
+		p.Revision--
+		return fmt.Errorf("failed to save project: %w", err)
+	}
+
+	s.project = p
+	if disk, err := readTreeState(s.dir); err == nil {
+		s.disk = disk
+	}
+	s.notify(DiffProjects(before, p))
+	return nil
+}
+
+// Reload implements Reloader like YAMLStore.Reload, for every file of the
+// directory.
+func (s *DirStore) Reload() ([]Change, error) {
+	defer s.flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed, disk, err := s.disk.changed(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check project directory: %w", err)
+	}
+	if !changed {
+		s.disk = disk
+		return nil, nil
+	}
+
+	project, err := loadProjectDirectory(s.dir)
+	if err == nil {
+		err = validateProject(project)
+	}
+	if err != nil {
+		if disk.hash == s.rejected {
+			return nil, nil
+		}
+		s.rejected = disk.hash
+		return nil, err
+	}
+	if s.project != nil {
+		project.Revision = s.project.Revision + 1
+	}
+	warnDanglingReferences(s.dir, project)
+
+	changes := DiffProjects(s.project, project)
+	s.project = project
+	s.disk = disk
+	s.notify(changes)
+	return changes, nil
+}
+
+func (s *DirStore) Delete() error {
+	defer s.flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.project != nil {
+		if err := s.createBackup(BackupDeleted); err != nil {
+			fmt.Printf("Warning: failed to create backup: %v\n", err)
+		}
+	}
+
+	for _, kind := range dirEntityKinds {
+		if err := os.RemoveAll(filepath.Join(s.dir, kind)); err != nil {
+			return fmt.Errorf("failed to delete project files: %w", err)
+		}
+	}
+	if err := os.Remove(filepath.Join(s.dir, workspaceProjectFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete project file: %w", err)
+	}
+	s.disk = treeState{}
+
+	s.notify(DiffProjects(s.project, nil))
+	s.project = nil
+	return nil
+}
+
+// Backup writes the project as a single YAML file, so backups of both
+// layouts can be browsed and restored the same way.
+func (s *DirStore) Backup() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.project == nil {
+		return fmt.Errorf("no project to backup")
+	}
+	return s.createBackup(BackupManual)
+}
+
+func (s *DirStore) createBackup(kind string) error {
+	if s.project == nil {
+		return nil
+	}
+	if err := SaveProjectToFile(s.project, s.backups.path(kind)); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	s.backups.taken(kind)
+	return nil
+}
+
+func (s *DirStore) Backups() ([]BackupInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.list()
+}
+
+func (s *DirStore) LoadBackup(name string) (*models.Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.load(name)
+}
+
+func (s *DirStore) BackupFile(name string) (string, error) {
+	return s.backups.file(name)
+}
+
+func (s *DirStore) BackupPolicy() BackupPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.policy
+}
+
+func (s *DirStore) SetBackupPolicy(policy BackupPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups.policy = policy
+}
+
+// GetPath returns the path of project.yaml, so files kept next to the
+// project, such as its history, land in the project directory.
+func (s *DirStore) GetPath() string {
+	return filepath.Join(s.dir, workspaceProjectFile)
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/models"
+	"github.com/google/uuid"
+)
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+var ErrNothingToRedo = errors.New("nothing to redo")
+
+// ErrHistoryConflict is returned by Undo and Redo when an entity the entry
+// changes is no longer in the state the entry left it in.
+var ErrHistoryConflict = errors.New("project changed since this history entry")
+
+type HistoryEntry struct {
+	ID          string    `json:"id"`
+	Time        time.Time `json:"time"`
+	Description string    `json:"description"`
+	Changes     []Change  `json:"changes"`
+}
+
+type historyFile struct {
+	Undo []HistoryEntry `json:"undo"`
+	Redo []HistoryEntry `json:"redo"`
+}
+
+// HistoryStore wraps a ProjectStore and records every save as an entry that
+// can be undone and redone. The history is bounded and persisted next to the
+// project file.
+type HistoryStore struct {
+	ProjectStore
+
+	mu    sync.Mutex
+	path  string
+	limit int
+	undo  []HistoryEntry
+	redo  []HistoryEntry
+}
+
+func NewHistoryStore(store ProjectStore, limit int) *HistoryStore {
+	h := &HistoryStore{
+		ProjectStore: store,
+		path:         filepath.Join(filepath.Dir(store.GetPath()), "history.json"),
+		limit:        limit,
+	}
+	if data, err := os.ReadFile(h.path); err == nil {
+		var f historyFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			fmt.Printf("Warning: ignoring unreadable history %s: %v\n", h.path, err)
+		} else {
+			h.undo, h.redo = f.Undo, f.Redo
+		}
+	}
+	return h
+}
+
+func (h *HistoryStore) Save(p *models.Project) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	before := h.ProjectStore.Get()
+	if err := h.ProjectStore.Save(p); err != nil {
+		return err
+	}
+	changes := DiffProjects(before, h.ProjectStore.Get())
+	if len(changes) == 0 {
+		return nil
+	}
+	h.undo = append(h.undo, HistoryEntry{ID: uuid.NewString(), Time: time.Now(), Description: describeChanges(changes), Changes: changes})
+	if len(h.undo) > h.limit {
+		h.undo = h.undo[len(h.undo)-h.limit:]
+	}
+	h.redo = nil
+	h.persist()
+	return nil
+}
+
+// Undo reverts the most recent entry and returns it.
+func (h *HistoryStore) Undo() (*HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.undo) == 0 {
+		return nil, ErrNothingToUndo
+	}
+	entry := h.undo[len(h.undo)-1]
+	if err := h.apply(entry, false); err != nil {
+		return nil, err
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, entry)
+	h.persist()
+	return &entry, nil
+}
+
+// Redo replays the most recently undone entry and returns it.
+func (h *HistoryStore) Redo() (*HistoryEntry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.redo) == 0 {
+		return nil, ErrNothingToRedo
+	}
+	entry := h.redo[len(h.redo)-1]
+	if err := h.apply(entry, true); err != nil {
+		return nil, err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, entry)
+	h.persist()
+	return &entry, nil
+}
+
+// History returns the undo and redo stacks, most recent entry first.
+func (h *HistoryStore) History() (undo, redo []HistoryEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.undo) - 1; i >= 0; i-- {
+		undo = append(undo, h.undo[i])
+	}
+	for i := len(h.redo) - 1; i >= 0; i-- {
+		redo = append(redo, h.redo[i])
+	}
+	return undo, redo
+}
+
+// Reload implements Reloader for the wrapped store. The history is cleared
+// when the reload changed the project: its entries describe states the
+// project files no longer hold.
+func (h *HistoryStore) Reload() ([]Change, error) {
+	reloader, ok := h.ProjectStore.(Reloader)
+	if !ok {
+		return nil, nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changes, err := reloader.Reload()
+	if len(changes) > 0 && (len(h.undo) > 0 || len(h.redo) > 0) {
+		h.undo, h.redo = nil, nil
+		h.persist()
+	}
+	return changes, err
+}
+
+func (h *HistoryStore) apply(entry HistoryEntry, forward bool) error {
+	current := h.ProjectStore.Get()
+	if current == nil {
+		return fmt.Errorf("no project loaded")
+	}
+	for _, c := range entry.Changes {
+		expected := c.After
+		if forward {
+			expected = c.Before
+		}
+		state, err := entityState(current, c.Entity, c.ID)
+		if err != nil {
+			return err
+		}
+		if !sameState(state, expected) {
+			name := c.Name
+			if name == "" {
+				name = c.ID
+			}
+			return fmt.Errorf("%w: %s %q was modified", ErrHistoryConflict, strings.ReplaceAll(c.Entity, "_", " "), name)
+		}
+	}
+	p, err := ApplyChanges(current, entry.Changes, forward)
+	if err != nil {
+		return err
+	}
+	if err := h.ProjectStore.Save(p); err != nil {
+		return fmt.Errorf("failed to save project: %w", err)
+	}
+	return nil
+}
+
+func (h *HistoryStore) persist() {
+	data, err := json.Marshal(historyFile{Undo: h.undo, Redo: h.redo})
+	if err != nil {
+		fmt.Printf("Warning: failed to encode history: %v\n", err)
+		return
+	}
+	tempFile := h.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		fmt.Printf("Warning: failed to save history: %v\n", err)
+		return
+	}
+	if err := os.Rename(tempFile, h.path); err != nil {
+		os.Remove(tempFile)
+		fmt.Printf("Warning: failed to save history: %v\n", err)
+	}
+}
+
+func describeChanges(changes []Change) string {
+	c := changes[0]
+	verb := map[string]string{OpCreate: "Created", OpUpdate: "Updated", OpDelete: "Deleted"}[c.Op]
+	entity := strings.ReplaceAll(c.Entity, "_", " ")
+	if len(changes) > 1 {
+		for _, other := range changes[1:] {
+			if other.Entity != c.Entity || other.Op != c.Op {
+				return fmt.Sprintf("%d changes", len(changes))
+			}
+		}
+		return fmt.Sprintf("%s %d %ss", verb, len(changes), entity)
+	}
+	if c.Entity == "project" {
+		return "Updated project settings"
+	}
+	if c.Name != "" {
+		return fmt.Sprintf("%s %s %q", verb, entity, c.Name)
+	}
+	return fmt.Sprintf("%s %s", verb, entity)
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+
+	"elano.fr/src/backend/models"
+	"github.com/google/uuid"
+)
+
+// MergeSelection names the entities of another project to bring into the
+// current one.
+type MergeSelection struct {
+	FixtureIDs []string `json:"fixture_ids"`
+	PresetIDs  []string `json:"preset_ids"`
+	ShowIDs    []string `json:"show_ids"`
+}
+
+// AddressConflict is a DMX address used both by an imported fixture and by a
+// fixture already in the project.
+type AddressConflict struct {
+	Address             int    `json:"address"`
+	FixtureID           string `json:"fixture_id"`
+	FixtureName         string `json:"fixture_name"`
+	ExistingFixtureID   string `json:"existing_fixture_id"`
+	ExistingFixtureName string `json:"existing_fixture_name"`
+}
+
+type MergeResult struct {
+	Project   *models.Project   `json:"-"`
+	IDs       IDMap             `json:"id_map"`
+	Fixtures  []models.Fixture  `json:"fixtures"`
+	Palettes  []models.Palette  `json:"palettes"`
+	Presets   []models.Preset   `json:"presets"`
+	Shows     []models.Show     `json:"shows"`
+	Conflicts []AddressConflict `json:"conflicts"`
+}
+
+// MergeProjects copies the selected entities of src into a copy of dst under
+// fresh IDs. Presets played by selected shows and palettes used by selected
+// presets are brought along. Palette references to fixtures that are neither
+// selected nor already present in dst are an error. dst is not modified.
+func MergeProjects(dst, src *models.Project, sel MergeSelection) (*MergeResult, error) {
+	fixtures := make(map[string]bool)
+	for _, id := range sel.FixtureIDs {
+		if findFixture(src, id) < 0 {
+			return nil, fmt.Errorf("fixture %s not found in imported project", id)
+		}
+		fixtures[id] = true
+	}
+	presets := make(map[string]bool)
+	for _, id := range sel.PresetIDs {
+		if findPreset(src, id) < 0 {
+			return nil, fmt.Errorf("preset %s not found in imported project", id)
+		}
+		presets[id] = true
+	}
+	shows := make(map[string]bool)
+	for _, id := range sel.ShowIDs {
+		i := findShow(src, id)
+		if i < 0 {
+			return nil, fmt.Errorf("show %s not found in imported project", id)
+		}
+		shows[id] = true
+		for _, step := range src.Shows[i].Steps {
+			if findPreset(src, step.PresetID) < 0 {
+				return nil, fmt.Errorf("show %q plays unknown preset %s", src.Shows[i].Name, step.PresetID)
+			}
+			presets[step.PresetID] = true
+		}
+	}
+	palettes := make(map[string]bool)
+	for _, pr := range src.Presets {
+		if !presets[pr.ID] {
+			continue
+		}
+		for _, ref := range pr.Palettes {
+			palettes[ref.PaletteID] = true
+			for _, id := range ref.FixtureIDs {
+				if !fixtures[id] && findFixture(dst, id) < 0 {
+					return nil, fmt.Errorf("preset %q uses fixture %s, which is not selected", pr.Name, id)
+				}
+			}
+		}
+	}
+
+	part := &models.Project{
+		Fixtures: []models.Fixture{},
+		Palettes: []models.Palette{},
+		Presets:  []models.Preset{},
+		Shows:    []models.Show{},
+	}
+	m := newIDMap()
+	for _, f := range src.Fixtures {
+		if fixtures[f.ID] {
+			part.Fixtures = append(part.Fixtures, f)
+			m.Fixtures[f.ID] = uuid.New().String()
+		}
+	}
+	for _, pal := range src.Palettes {
+		if palettes[pal.ID] {
+			part.Palettes = append(part.Palettes, pal)
+			m.Palettes[pal.ID] = uuid.New().String()
+		}
+	}
+	for _, pr := range src.Presets {
+		if presets[pr.ID] {
+			part.Presets = append(part.Presets, pr)
+			m.Presets[pr.ID] = uuid.New().String()
+		}
+	}
+	for _, s := range src.Shows {
+		if shows[s.ID] {
+			part.Shows = append(part.Shows, s)
+			m.Shows[s.ID] = uuid.New().String()
+		}
+	}
+	m.apply(part)
+
+	merged := *dst
+	merged.Fixtures = append(append([]models.Fixture{}, dst.Fixtures...), part.Fixtures...)
+	merged.Palettes = append(append([]models.Palette{}, dst.Palettes...), part.Palettes...)
+	merged.Presets = append(append([]models.Preset{}, dst.Presets...), part.Presets...)
+	merged.Shows = append(append([]models.Show{}, dst.Shows...), part.Shows...)
+
+	return &MergeResult{
+		Project:   &merged,
+		IDs:       m,
+		Fixtures:  part.Fixtures,
+		Palettes:  part.Palettes,
+		Presets:   part.Presets,
+		Shows:     part.Shows,
+		Conflicts: AddressConflicts(dst.Fixtures, part.Fixtures),
+	}, nil
+}
+
+// AddressConflicts lists the DMX addresses, coarse or fine, that fixtures in
+// added share with fixtures in existing.
+func AddressConflicts(existing, added []models.Fixture) []AddressConflict {
+	used := make(map[int]models.Fixture)
+	for _, f := range existing {
+		for _, addr := range fixtureAddresses(f) {
+			if _, ok := used[addr]; !ok {
+				used[addr] = f
+			}
+		}
+	}
+	conflicts := []AddressConflict{}
+	for _, f := range added {
+		for _, addr := range fixtureAddresses(f) {
+			if other, ok := used[addr]; ok {
+				conflicts = append(conflicts, AddressConflict{
+					Address:             addr,
+					FixtureID:           f.ID,
+					FixtureName:         f.Name,
+					ExistingFixtureID:   other.ID,
+					ExistingFixtureName: other.Name,
+				})
+			}
+		}
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].Address < conflicts[j].Address })
+	return conflicts
+}
+
+func fixtureAddresses(f models.Fixture) []int {
+	seen := make(map[int]bool)
+	var addrs []int
+	for _, ch := range f.Channels {
+		for _, addr := range []int{ch.ChannelAddress, ch.FineChannelAddress} {
+			if addr > 0 && !seen[addr] {
+				seen[addr] = true
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs
+}
+
+func findFixture(p *models.Project, id string) int {
+	for i, f := range p.Fixtures {
+		if f.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func findPreset(p *models.Project, id string) int {
+	for i, pr := range p.Presets {
+		if pr.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func findShow(p *models.Project, id string) int {
+	for i, s := range p.Shows {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,21 +13,88 @@ import (
 	"github.com/google/uuid"
 )
 
+// ErrRevisionConflict is returned by Save when the project was saved by
+// someone else since it was read: the revision of the saved project must match
+// the revision currently stored.
+var ErrRevisionConflict = errors.New("project was modified concurrently")
+
+// ErrInvalidProject is returned by Save when the project holds invalid data,
+// which would keep it from loading again.
+var ErrInvalidProject = errors.New("project validation failed")
+
 type ProjectStore interface {
 	Get() *models.Project
 	Save(*models.Project) error
 	Delete() error
 	Backup() error
 	GetPath() string
+	OnChange(ChangeListener)
+}
+
+// ChangeListener is called after every successful save or delete with the
+// entities that changed.
+type ChangeListener func([]Change)
+
+// listeners holds the change listeners of a store, which embeds it to
+// implement OnChange.
+type listeners struct {
+	mu      sync.Mutex
+	fns     []ChangeListener
+	pending [][]Change
+	// running serialises flush, so listeners get the changes one batch at
+	// a time.
+	running sync.Mutex
+}
+
+func (l *listeners) OnChange(fn ChangeListener) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fns = append(l.fns, fn)
+}
+
+// notify queues changes for the listeners. Stores call it with their own lock
+// held, so batches are queued in the order they were saved, and call flush
+// once the lock is released, so that a slow listener never holds up the
+// store. Listeners must not save to the store.
+func (l *listeners) notify(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+	l.mu.Lock()
+	l.pending = append(l.pending, changes)
+	l.mu.Unlock()
+}
+
+// flush runs the listeners for every queued batch of changes, in order.
+func (l *listeners) flush() {
+	l.running.Lock()
+	defer l.running.Unlock()
+	for {
+		l.mu.Lock()
+		if len(l.pending) == 0 {
+			l.mu.Unlock()
+			return
+		}
+		changes := l.pending[0]
+		l.pending = l.pending[1:]
+		fns := l.fns
+		l.mu.Unlock()
+		for _, fn := range fns {
+			fn(changes)
+		}
+	}
 }
 
 type YAMLStore struct {
-	mu           sync.RWMutex
-	project      *models.Project
-	filepath     string
-	backupDir    string
-	maxBackups   int
-	lastModified time.Time
+	mu       sync.RWMutex
+	project  *models.Project
+	filepath string
+	backups  *backupSet
+	listeners
+	// disk is the project file as last read or written by the store, and
+	// rejected the content of the last reload that failed validation.
+	disk     fileState
+	rejected [sha256.Size]byte
 }
 
 func NewYAMLStore(path string) (*YAMLStore, error) {
@@ -34,19 +103,22 @@ func NewYAMLStore(path string) (*YAMLStore, error) {
 		return nil, err
 	}
 
-	store := &YAMLStore{
-		project:      project,
-		filepath:     path,
-		backupDir:    filepath.Join(filepath.Dir(path), "backups"),
-		maxBackups:   10,
-		lastModified: time.Now(),
+	backups, err := newBackupSet(filepath.Join(filepath.Dir(path), "backups"), ".yaml")
+	if err != nil {
+		return nil, err
 	}
-
-	if err := os.MkdirAll(store.backupDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	warnDanglingReferences(path, project)
+	disk, _, err := readFileState(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %q: %w", path, err)
 	}
 
-	return store, nil
+	return &YAMLStore{
+		project:  project,
+		filepath: path,
+		backups:  backups,
+		disk:     disk,
+	}, nil
 }
 
 func NewYAMLStoreWithDefault(path string) (*YAMLStore, error) {
@@ -79,16 +151,31 @@ func (s *YAMLStore) Get() *models.Project {
 		return nil
 	}
 
-	projectCopy := *s.project
+	return copyProject(s.project)
+}
+
+// copyProject returns a copy of p whose entity slices can be modified without
+// affecting p.
+func copyProject(p *models.Project) *models.Project {
+	projectCopy := *p
 
-	projectCopy.Fixtures = make([]models.Fixture, len(s.project.Fixtures))
-	copy(projectCopy.Fixtures, s.project.Fixtures)
+	projectCopy.Fixtures = make([]models.Fixture, len(p.Fixtures))
+	copy(projectCopy.Fixtures, p.Fixtures)
 
-	projectCopy.Presets = make([]models.Preset, len(s.project.Presets))
-	copy(projectCopy.Presets, s.project.Presets)
+	projectCopy.Presets = make([]models.Preset, len(p.Presets))
+	copy(projectCopy.Presets, p.Presets)
 
-	projectCopy.Shows = make([]models.Show, len(s.project.Shows))
-	copy(projectCopy.Shows, s.project.Shows)
+	projectCopy.Shows = make([]models.Show, len(p.Shows))
+	copy(projectCopy.Shows, p.Shows)
+
+	projectCopy.Schedules = make([]models.Schedule, len(p.Schedules))
+	copy(projectCopy.Schedules, p.Schedules)
+
+	projectCopy.PixelMaps = make([]models.PixelMap, len(p.PixelMaps))
+	copy(projectCopy.PixelMaps, p.PixelMaps)
+
+	projectCopy.Palettes = make([]models.Palette, len(p.Palettes))
+	copy(projectCopy.Palettes, p.Palettes)
 
 	return &projectCopy
 }
@@ -98,106 +185,178 @@ func (s *YAMLStore) Save(p *models.Project) error {
 		return fmt.Errorf("cannot save nil project")
 	}
 
+	defer s.flush()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.project != nil && time.Since(s.lastModified) > 5*time.Minute {
-		if err := s.createBackup(); err != nil {
-			fmt.Printf("Warning: failed to create backup: %v\n", err)
-		}
+	before := s.project
+	if err := s.save(p); err != nil {
+		return err
+	}
+	s.notify(DiffProjects(before, p))
+	return nil
+}
+
+func (s *YAMLStore) save(p *models.Project) error {
+	if s.project != nil && p.Revision != s.project.Revision {
+		return fmt.Errorf("%w: expected revision %d, current is %d", ErrRevisionConflict, p.Revision, s.project.Revision)
 	}
 
-	if err := s.validateProject(p); err != nil {
+	if err := validateProject(p); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProject, err)
+	}
+	if err := checkReferences(s.project, p); err != nil {
 		return fmt.Errorf("project validation failed: %w", err)
 	}
 
+	if changed, _, _, err := s.disk.changed(s.filepath); err != nil {
+		return fmt.Errorf("failed to check project file: %w", err)
+	} else if changed {
+		return fmt.Errorf("%w: reload it before saving", ErrModifiedOnDisk)
+	}
+
+	if s.project != nil && s.backups.due(time.Now()) {
+		if err := s.createBackup(BackupManual); err != nil {
+			fmt.Printf("Warning: failed to create backup: %v\n", err)
+		}
+	}
+
+	revision := p.Revision
+	p.Revision++
+
 	tempFile := s.filepath + ".tmp"
 	if err := SaveProjectToFile(p, tempFile); err != nil {
+		p.Revision = revision
 		return fmt.Errorf("failed to save to temporary file: %w", err)
 	}
 
 	if err := os.Rename(tempFile, s.filepath); err != nil {
 		os.Remove(tempFile)
+		p.Revision = revision
 		return fmt.Errorf("failed to save project: %w", err)
 	}
 
 	s.project = p
-	s.lastModified = time.Now()
+	if disk, _, err := readFileState(s.filepath); err == nil {
+		s.disk = disk
+	}
 	return nil
 }
 
+// Reload implements Reloader. The reloaded project gets the next revision so
+// clients holding the previous one cannot overwrite it.
+func (s *YAMLStore) Reload() ([]Change, error) {
+	defer s.flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed, disk, data, err := s.disk.changed(s.filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check project file: %w", err)
+	}
+	if !changed {
+		s.disk = disk
+		return nil, nil
+	}
+
+	project, err := decodeReloaded(data)
+	if err != nil {
+		if disk.hash == s.rejected {
+			return nil, nil
+		}
+		s.rejected = disk.hash
+		return nil, fmt.Errorf("%q: %w", s.filepath, err)
+	}
+	if s.project != nil {
+		project.Revision = s.project.Revision + 1
+	}
+	warnDanglingReferences(s.filepath, project)
+
+	changes := DiffProjects(s.project, project)
+	s.project = project
+	s.disk = disk
+	s.notify(changes)
+	return changes, nil
+}
+
 func (s *YAMLStore) Delete() error {
+	defer s.flush()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.project != nil {
-		backupPath := filepath.Join(s.backupDir, fmt.Sprintf("deleted_%s.yaml", time.Now().Format("20060102_150405")))
-		SaveProjectToFile(s.project, backupPath)
+		if err := s.createBackup(BackupDeleted); err != nil {
+			fmt.Printf("Warning: failed to create backup: %v\n", err)
+		}
 	}
 
 	if err := os.Remove(s.filepath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete project file: %w", err)
 	}
+	s.disk = fileState{}
 
+	s.notify(DiffProjects(s.project, nil))
 	s.project = nil
 	return nil
 }
 
 func (s *YAMLStore) Backup() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	if s.project == nil {
 		return fmt.Errorf("no project to backup")
 	}
 
-	return s.createBackup()
+	return s.createBackup(BackupManual)
 }
 
-func (s *YAMLStore) GetPath() string {
-	return s.filepath
+func (s *YAMLStore) Backups() ([]BackupInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.list()
 }
 
-func (s *YAMLStore) createBackup() error {
-	if s.project == nil {
-		return nil
-	}
-
-	timestamp := time.Now().Format("20060102_150405")
-	backupPath := filepath.Join(s.backupDir, fmt.Sprintf("backup_%s.yaml", timestamp))
+func (s *YAMLStore) LoadBackup(name string) (*models.Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.load(name)
+}
 
-	if err := SaveProjectToFile(s.project, backupPath); err != nil {
-		return fmt.Errorf("failed to create backup: %w", err)
-	}
+func (s *YAMLStore) BackupFile(name string) (string, error) {
+	return s.backups.file(name)
+}
 
-	go s.cleanOldBackups()
+func (s *YAMLStore) BackupPolicy() BackupPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.policy
+}
 
-	return nil
+func (s *YAMLStore) SetBackupPolicy(policy BackupPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups.policy = policy
 }
 
-func (s *YAMLStore) cleanOldBackups() {
-	files, err := os.ReadDir(s.backupDir)
-	if err != nil {
-		return
-	}
+func (s *YAMLStore) GetPath() string {
+	return s.filepath
+}
 
-	var backups []os.DirEntry
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".yaml" {
-			backups = append(backups, file)
-		}
+func (s *YAMLStore) createBackup(kind string) error {
+	if s.project == nil {
+		return nil
 	}
 
-	if len(backups) <= s.maxBackups {
-		return
+	if err := SaveProjectToFile(s.project, s.backups.path(kind)); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
 	}
 
-	for i := 0; i < len(backups)-s.maxBackups; i++ {
-		os.Remove(filepath.Join(s.backupDir, backups[i].Name()))
-	}
+	s.backups.taken(kind)
+	return nil
 }
 
-func (s *YAMLStore) validateProject(p *models.Project) error {
+func validateProject(p *models.Project) error {
 	if p.ID == "" {
 		return fmt.Errorf("project ID is required")
 	}
@@ -251,5 +410,38 @@ func (s *YAMLStore) validateProject(p *models.Project) error {
 		showIDs[s.ID] = true
 	}
 
-	return nil
+	scheduleIDs := make(map[string]bool)
+	for _, s := range p.Schedules {
+		if s.ID == "" {
+			return fmt.Errorf("schedule ID cannot be empty")
+		}
+		if scheduleIDs[s.ID] {
+			return fmt.Errorf("duplicate schedule ID: %s", s.ID)
+		}
+		scheduleIDs[s.ID] = true
+	}
+
+	pixelMapIDs := make(map[string]bool)
+	for _, pm := range p.PixelMaps {
+		if pm.ID == "" {
+			return fmt.Errorf("pixel map ID cannot be empty")
+		}
+		if pixelMapIDs[pm.ID] {
+			return fmt.Errorf("duplicate pixel map ID: %s", pm.ID)
+		}
+		pixelMapIDs[pm.ID] = true
+	}
+
+	paletteIDs := make(map[string]bool)
+	for _, pal := range p.Palettes {
+		if pal.ID == "" {
+			return fmt.Errorf("palette ID cannot be empty")
+		}
+		if paletteIDs[pal.ID] {
+			return fmt.Errorf("duplicate palette ID: %s", pal.ID)
+		}
+		paletteIDs[pal.ID] = true
+	}
+
+	return validateContent(p)
 }
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"elano.fr/src/backend/models"
+)
+
+// Listeners run once the store is unlocked, so they can read it, and get the
+// changes in the order they were saved.
+func TestChangeListeners(t *testing.T) {
+	store, err := NewYAMLStoreWithDefault(filepath.Join(t.TempDir(), "project.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	store.OnChange(func(changes []Change) {
+		project := store.Get()
+		for _, ch := range changes {
+			if ch.Entity == "fixture" {
+				got = append(got, ch.Op+" "+ch.ID+" "+project.Name)
+			}
+		}
+	})
+
+	for _, id := range []string{"a", "b"} {
+		project := store.Get()
+		project.Name = "Saved " + id
+		project.Fixtures = append(project.Fixtures, models.Fixture{ID: id, Name: id})
+		if err := store.Save(project); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+
+	want := []string{"create a Saved a", "create b Saved b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("listeners got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"elano.fr/src/backend/models"
+)
+
+var (
+	ErrDanglingReference = errors.New("reference to a missing entity")
+	ErrEntityNotFound    = errors.New("entity not found")
+	ErrInUse             = errors.New("entity is in use")
+)
+
+// Reference is a field of an entity that points at another entity.
+type Reference struct {
+	Entity   string `json:"entity"`
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Field    string `json:"field"`
+	Target   string `json:"target"`
+	TargetID string `json:"target_id"`
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%s %q %s references unknown %s %s", r.Entity, r.Name, r.Field, r.Target, r.TargetID)
+}
+
+// References lists every reference between the entities of p.
+func References(p *models.Project) []Reference {
+	var refs []Reference
+	for _, s := range p.Shows {
+		for i, step := range s.Steps {
+			refs = append(refs, Reference{"show", s.ID, s.Name, fmt.Sprintf("steps[%d].preset_id", i), "preset", step.PresetID})
+		}
+	}
+	for _, pr := range p.Presets {
+		for i, ref := range pr.Palettes {
+			refs = append(refs, Reference{"preset", pr.ID, pr.Name, fmt.Sprintf("palettes[%d].palette_id", i), "palette", ref.PaletteID})
+			for j, id := range ref.FixtureIDs {
+				refs = append(refs, Reference{"preset", pr.ID, pr.Name, fmt.Sprintf("palettes[%d].fixture_ids[%d]", i, j), "fixture", id})
+			}
+		}
+	}
+	for _, sc := range p.Schedules {
+		refs = append(refs, actionReferences(sc, "action", sc.Action)...)
+		if sc.EndAction != nil {
+			refs = append(refs, actionReferences(sc, "end_action", *sc.EndAction)...)
+		}
+	}
+	for _, pm := range p.PixelMaps {
+		for i, c := range pm.Cells {
+			if c.FixtureID != "" {
+				refs = append(refs, Reference{"pixel_map", pm.ID, pm.Name, fmt.Sprintf("cells[%d].fixture_id", i), "fixture", c.FixtureID})
+			}
+		}
+	}
+	return refs
+}
+
+func actionReferences(sc models.Schedule, field string, a models.ScheduleAction) []Reference {
+	var refs []Reference
+	if a.ShowID != "" {
+		refs = append(refs, Reference{"schedule", sc.ID, sc.Name, field + ".show_id", "show", a.ShowID})
+	}
+	if a.PresetID != "" {
+		refs = append(refs, Reference{"schedule", sc.ID, sc.Name, field + ".preset_id", "preset", a.PresetID})
+	}
+	return refs
+}
+
+func entityIDs(p *models.Project) map[string]map[string]bool {
+	ids := map[string]map[string]bool{
+		"fixture": {}, "palette": {}, "preset": {}, "show": {},
+	}
+	for _, f := range p.Fixtures {
+		ids["fixture"][f.ID] = true
+	}
+	for _, pal := range p.Palettes {
+		ids["palette"][pal.ID] = true
+	}
+	for _, pr := range p.Presets {
+		ids["preset"][pr.ID] = true
+	}
+	for _, s := range p.Shows {
+		ids["show"][s.ID] = true
+	}
+	return ids
+}
+
+// DanglingReferences lists the references of p to entities it does not have.
+func DanglingReferences(p *models.Project) []Reference {
+	ids := entityIDs(p)
+	var dangling []Reference
+	for _, ref := range References(p) {
+		if !ids[ref.Target][ref.TargetID] {
+			dangling = append(dangling, ref)
+		}
+	}
+	return dangling
+}
+
+// Dependents lists the references of p to the given entity.
+func Dependents(p *models.Project, target, id string) []Reference {
+	var deps []Reference
+	for _, ref := range References(p) {
+		if ref.Target == target && ref.TargetID == id {
+			deps = append(deps, ref)
+		}
+	}
+	return deps
+}
+
+// warnDanglingReferences flags the broken references of a project being
+// loaded. They are reported rather than rejected so the project can still be
+// opened and repaired.
+func warnDanglingReferences(path string, p *models.Project) {
+	for _, ref := range DanglingReferences(p) {
+		fmt.Printf("Warning: %s: %s\n", path, ref)
+	}
+}
+
+// checkReferences rejects a save that leaves a reference pointing nowhere.
+// References that were already dangling before are tolerated so a project
+// loaded with broken references can still be edited and repaired.
+func checkReferences(before, after *models.Project) error {
+	type key struct{ entity, id, target, targetID string }
+	known := make(map[key]bool)
+	if before != nil {
+		for _, ref := range DanglingReferences(before) {
+			known[key{ref.Entity, ref.ID, ref.Target, ref.TargetID}] = true
+		}
+	}
+	for _, ref := range DanglingReferences(after) {
+		if !known[key{ref.Entity, ref.ID, ref.Target, ref.TargetID}] {
+			return fmt.Errorf("%w: %s", ErrDanglingReference, ref)
+		}
+	}
+	return nil
+}
+
+// InUseError is returned by RemoveEntity when other entities still use the
+// entity to remove.
+type InUseError struct {
+	Dependents []Reference
+}
+
+func (e *InUseError) Error() string {
+	return fmt.Sprintf("%s is used by %d other entities", e.Dependents[0].Target, len(e.Dependents))
+}
+
+func (e *InUseError) Is(target error) bool { return target == ErrInUse }
+
+// RemoveOptions says what happens to the users of a removed entity. With
+// ReplaceWith, they are pointed at another entity of the same kind instead.
+// With Cascade, the steps, palette references and pixel cells using it are
+// dropped, as are shows, presets, palette references and pixel maps left
+// empty and schedules triggering a removed show or preset. Otherwise removing an entity
+// in use fails with an InUseError.
+type RemoveOptions struct {
+	Cascade     bool
+	ReplaceWith string
+}
+
+// RemoveEntity removes a fixture, palette, preset or show from p and deals
+// with its dependents according to opts. It returns the references that were
+// rewritten or removed. Unknown IDs fail with ErrEntityNotFound.
+func RemoveEntity(p *models.Project, kind, id string, opts RemoveOptions) ([]Reference, error) {
+	ids := entityIDs(p)
+	if _, ok := ids[kind]; !ok {
+		return nil, fmt.Errorf("unknown entity type %q", kind)
+	}
+	if !ids[kind][id] {
+		return nil, fmt.Errorf("%w: %s %s", ErrEntityNotFound, kind, id)
+	}
+	if opts.ReplaceWith != "" && (opts.ReplaceWith == id || !ids[kind][opts.ReplaceWith]) {
+		return nil, fmt.Errorf("%w: replacement %s %s", ErrEntityNotFound, kind, opts.ReplaceWith)
+	}
+
+	deps := Dependents(p, kind, id)
+	if len(deps) > 0 && !opts.Cascade && opts.ReplaceWith == "" {
+		return deps, &InUseError{Dependents: deps}
+	}
+
+	switch kind {
+	case "fixture":
+		p.Fixtures = removeByID(p.Fixtures, id, func(f models.Fixture) string { return f.ID })
+	case "palette":
+		p.Palettes = removeByID(p.Palettes, id, func(pal models.Palette) string { return pal.ID })
+	case "preset":
+		p.Presets = removeByID(p.Presets, id, func(pr models.Preset) string { return pr.ID })
+	case "show":
+		p.Shows = removeByID(p.Shows, id, func(s models.Show) string { return s.ID })
+	}
+	if len(deps) == 0 {
+		return deps, nil
+	}
+
+	if opts.ReplaceWith != "" {
+		m := newIDMap()
+		switch kind {
+		case "fixture":
+			m.Fixtures[id] = opts.ReplaceWith
+		case "palette":
+			m.Palettes[id] = opts.ReplaceWith
+		case "preset":
+			m.Presets[id] = opts.ReplaceWith
+		case "show":
+			m.Shows[id] = opts.ReplaceWith
+		}
+		m.apply(p)
+		return deps, nil
+	}
+
+	cascade(p, kind, id)
+	return deps, nil
+}
+
+// cascade drops everything in p that uses the removed entity.
+func cascade(p *models.Project, kind, id string) {
+	removedShows := map[string]bool{}
+	removedPresets := map[string]bool{}
+	switch kind {
+	case "show":
+		removedShows[id] = true
+	case "preset":
+		removedPresets[id] = true
+	case "fixture", "palette":
+		for i := range p.Presets {
+			pr := &p.Presets[i]
+			var refs []models.PaletteRef
+			for _, ref := range pr.Palettes {
+				if kind == "palette" && ref.PaletteID == id {
+					continue
+				}
+				var fixtureIDs []string
+				for _, f := range ref.FixtureIDs {
+					if kind != "fixture" || f != id {
+						fixtureIDs = append(fixtureIDs, f)
+					}
+				}
+				if len(fixtureIDs) == 0 {
+					continue
+				}
+				ref.FixtureIDs = fixtureIDs
+				refs = append(refs, ref)
+			}
+			// A preset that only played the removed entity has nothing
+			// left to play, and goes with it.
+			if len(pr.Palettes) > 0 && len(refs) == 0 && len(pr.Channels) == 0 {
+				removedPresets[pr.ID] = true
+			}
+			if pr.Palettes != nil {
+				pr.Palettes = refs
+			}
+		}
+		p.Presets = removeIDs(p.Presets, removedPresets, func(pr models.Preset) string { return pr.ID })
+		if kind == "fixture" {
+			pixelMaps := p.PixelMaps[:0:0]
+			for _, pm := range p.PixelMaps {
+				cells := make([]models.PixelCell, 0, len(pm.Cells))
+				for _, c := range pm.Cells {
+					if c.FixtureID != id {
+						cells = append(cells, c)
+					}
+				}
+				if len(cells) > 0 {
+					pm.Cells = cells
+					pixelMaps = append(pixelMaps, pm)
+				}
+			}
+			p.PixelMaps = pixelMaps
+		}
+	}
+
+	if len(removedShows) == 0 && len(removedPresets) == 0 {
+		return
+	}
+	if len(removedPresets) > 0 {
+		shows := p.Shows[:0:0]
+		for _, s := range p.Shows {
+			steps := make([]models.ShowStep, 0, len(s.Steps))
+			for _, step := range s.Steps {
+				if !removedPresets[step.PresetID] {
+					steps = append(steps, step)
+				}
+			}
+			if len(steps) == 0 {
+				removedShows[s.ID] = true
+				continue
+			}
+			s.Steps = steps
+			shows = append(shows, s)
+		}
+		p.Shows = shows
+	}
+	schedules := p.Schedules[:0:0]
+	for _, sc := range p.Schedules {
+		uses := func(a models.ScheduleAction) bool { return removedShows[a.ShowID] || removedPresets[a.PresetID] }
+		if uses(sc.Action) || (sc.EndAction != nil && uses(*sc.EndAction)) {
+			continue
+		}
+		schedules = append(schedules, sc)
+	}
+	p.Schedules = schedules
+	p.Shows = removeIDs(p.Shows, removedShows, func(s models.Show) string { return s.ID })
+}
+
+func removeByID[T any](items []T, id string, idOf func(T) string) []T {
+	return removeIDs(items, map[string]bool{id: true}, idOf)
+}
+
+func removeIDs[T any](items []T, ids map[string]bool, idOf func(T) string) []T {
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		if !ids[idOf(item)] {
+			out = append(out, item)
+		}
+	}
+	return out
+}
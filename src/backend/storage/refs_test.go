@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"elano.fr/src/backend/models"
+)
+
+func TestRemoveEntityCascade(t *testing.T) {
+	newProject := func() *models.Project {
+		return &models.Project{
+			Fixtures: []models.Fixture{{ID: "f1"}, {ID: "f2"}},
+			Palettes: []models.Palette{{ID: "red"}},
+			Presets: []models.Preset{
+				{ID: "only-f1", Palettes: []models.PaletteRef{{PaletteID: "red", FixtureIDs: []string{"f1"}}}},
+				{ID: "f1-and-f2", Palettes: []models.PaletteRef{{PaletteID: "red", FixtureIDs: []string{"f1", "f2"}}}},
+				{ID: "with-channels", Channels: []models.ChannelValue{{DMXAddress: 1, Value: 255}}, Palettes: []models.PaletteRef{{PaletteID: "red", FixtureIDs: []string{"f1"}}}},
+			},
+			Shows: []models.Show{
+				{ID: "alone", Steps: []models.ShowStep{{PresetID: "only-f1"}}},
+				{ID: "mixed", Steps: []models.ShowStep{{PresetID: "only-f1"}, {PresetID: "with-channels"}}},
+			},
+			Schedules: []models.Schedule{
+				{ID: "run-alone", Action: models.ScheduleAction{ShowID: "alone"}},
+				{ID: "apply-only-f1", Action: models.ScheduleAction{PresetID: "only-f1"}},
+				{ID: "run-mixed", Action: models.ScheduleAction{ShowID: "mixed"}},
+			},
+		}
+	}
+	ids := func(p *models.Project) map[string][]string {
+		got := map[string][]string{}
+		for _, pr := range p.Presets {
+			got["presets"] = append(got["presets"], pr.ID)
+		}
+		for _, s := range p.Shows {
+			got["shows"] = append(got["shows"], s.ID)
+			for _, step := range s.Steps {
+				got["steps"] = append(got["steps"], s.ID+"/"+step.PresetID)
+			}
+		}
+		for _, sc := range p.Schedules {
+			got["schedules"] = append(got["schedules"], sc.ID)
+		}
+		return got
+	}
+
+	tests := []struct {
+		kind, id string
+		want     map[string][]string
+	}{
+		{"fixture", "f1", map[string][]string{
+			"presets":   {"f1-and-f2", "with-channels"},
+			"shows":     {"mixed"},
+			"steps":     {"mixed/with-channels"},
+			"schedules": {"run-mixed"},
+		}},
+		{"palette", "red", map[string][]string{
+			"presets":   {"with-channels"},
+			"shows":     {"mixed"},
+			"steps":     {"mixed/with-channels"},
+			"schedules": {"run-mixed"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			p := newProject()
+			if _, err := RemoveEntity(p, tt.kind, tt.id, RemoveOptions{Cascade: true}); err != nil {
+				t.Fatalf("RemoveEntity: %v", err)
+			}
+			if got := ids(p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("after removing %s %s: %v, want %v", tt.kind, tt.id, got, tt.want)
+			}
+			if dangling := DanglingReferences(p); len(dangling) > 0 {
+				t.Errorf("dangling references left: %v", dangling)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"elano.fr/src/backend/models"
+	"github.com/google/uuid"
+)
+
+// IDMap records the new ID given to each remapped entity, keyed by its old ID.
+type IDMap struct {
+	Fixtures  map[string]string `json:"fixtures"`
+	Palettes  map[string]string `json:"palettes"`
+	Presets   map[string]string `json:"presets"`
+	Shows     map[string]string `json:"shows"`
+	Schedules map[string]string `json:"schedules"`
+	PixelMaps map[string]string `json:"pixel_maps"`
+}
+
+func newIDMap() IDMap {
+	return IDMap{
+		Fixtures:  map[string]string{},
+		Palettes:  map[string]string{},
+		Presets:   map[string]string{},
+		Shows:     map[string]string{},
+		Schedules: map[string]string{},
+		PixelMaps: map[string]string{},
+	}
+}
+
+// RemapIDs gives every entity of p a fresh ID and rewrites the references
+// between them, so an imported file never collides with existing entities.
+// The project ID itself is left alone. p is modified in place.
+func RemapIDs(p *models.Project) IDMap {
+	m := newIDMap()
+	for _, f := range p.Fixtures {
+		m.Fixtures[f.ID] = uuid.New().String()
+	}
+	for _, pal := range p.Palettes {
+		m.Palettes[pal.ID] = uuid.New().String()
+	}
+	for _, pr := range p.Presets {
+		m.Presets[pr.ID] = uuid.New().String()
+	}
+	for _, s := range p.Shows {
+		m.Shows[s.ID] = uuid.New().String()
+	}
+	for _, sc := range p.Schedules {
+		m.Schedules[sc.ID] = uuid.New().String()
+	}
+	for _, pm := range p.PixelMaps {
+		m.PixelMaps[pm.ID] = uuid.New().String()
+	}
+	m.apply(p)
+	return m
+}
+
+// apply renames the entities of p listed in m and rewrites every reference to
+// them. IDs missing from m are kept.
+func (m IDMap) apply(p *models.Project) {
+	for i := range p.Fixtures {
+		p.Fixtures[i].ID = remap(m.Fixtures, p.Fixtures[i].ID)
+	}
+	for i := range p.Palettes {
+		p.Palettes[i].ID = remap(m.Palettes, p.Palettes[i].ID)
+	}
+	for i := range p.Presets {
+		pr := &p.Presets[i]
+		pr.ID = remap(m.Presets, pr.ID)
+		refs := make([]models.PaletteRef, len(pr.Palettes))
+		for j, ref := range pr.Palettes {
+			refs[j] = models.PaletteRef{PaletteID: remap(m.Palettes, ref.PaletteID)}
+			for _, id := range ref.FixtureIDs {
+				refs[j].FixtureIDs = append(refs[j].FixtureIDs, remap(m.Fixtures, id))
+			}
+		}
+		if pr.Palettes != nil {
+			pr.Palettes = refs
+		}
+	}
+	for i := range p.Shows {
+		s := &p.Shows[i]
+		s.ID = remap(m.Shows, s.ID)
+		steps := make([]models.ShowStep, len(s.Steps))
+		for j, step := range s.Steps {
+			step.PresetID = remap(m.Presets, step.PresetID)
+			steps[j] = step
+		}
+		s.Steps = steps
+	}
+	for i := range p.Schedules {
+		sc := &p.Schedules[i]
+		sc.ID = remap(m.Schedules, sc.ID)
+		sc.Action = m.action(sc.Action)
+		if sc.EndAction != nil {
+			end := m.action(*sc.EndAction)
+			sc.EndAction = &end
+		}
+	}
+	for i := range p.PixelMaps {
+		pm := &p.PixelMaps[i]
+		pm.ID = remap(m.PixelMaps, pm.ID)
+		cells := make([]models.PixelCell, len(pm.Cells))
+		for j, c := range pm.Cells {
+			c.FixtureID = remap(m.Fixtures, c.FixtureID)
+			cells[j] = c
+		}
+		pm.Cells = cells
+	}
+}
+
+func (m IDMap) action(a models.ScheduleAction) models.ScheduleAction {
+	a.ShowID = remap(m.Shows, a.ShowID)
+	a.PresetID = remap(m.Presets, a.PresetID)
+	return a
+}
+
+func remap(ids map[string]string, id string) string {
+	if newID, ok := ids[id]; ok {
+		return newID
+	}
+	return id
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentSchemaVersion is the schema_version written into project files.
+// Files without the field are version 0.
+const CurrentSchemaVersion = 1
+
+// schemaMigrations[v] upgrades a decoded project document from version v to
+// v+1. Migrations work on the raw document so they can read fields that no
+// longer exist in the models. Append a migration and bump
+// CurrentSchemaVersion whenever a model change would reject older files.
+var schemaMigrations = []func(doc map[string]any) error{
+	// 0 -> 1: schema_version is introduced. Early files could leave the
+	// entity lists out or null.
+	func(doc map[string]any) error {
+		for _, key := range []string{"fixtures", "presets", "shows"} {
+			if doc[key] == nil {
+				doc[key] = []any{}
+			}
+		}
+		return nil
+	},
+}
+
+func schemaVersion(doc map[string]any) (int, error) {
+	switch v := doc["schema_version"].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid schema_version %v", doc["schema_version"])
+}
+
+// migrateDocument runs the migrations needed to bring doc to the current
+// schema version and returns the version it started from.
+func migrateDocument(doc map[string]any) (int, error) {
+	from, err := schemaVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+	if from > CurrentSchemaVersion {
+		return from, fmt.Errorf("project schema version %d is newer than supported version %d", from, CurrentSchemaVersion)
+	}
+	for v := from; v < CurrentSchemaVersion; v++ {
+		if err := schemaMigrations[v](doc); err != nil {
+			return from, fmt.Errorf("failed to migrate project from schema version %d to %d: %w", v, v+1, err)
+		}
+		doc["schema_version"] = v + 1
+	}
+	return from, nil
+}
+
+// upgradeYAML returns data migrated to the current schema version, or data
+// itself when it is already current, along with the version it started from.
+func upgradeYAML(data []byte) ([]byte, int, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	if doc == nil {
+		return data, CurrentSchemaVersion, nil
+	}
+	from, err := migrateDocument(doc)
+	if err != nil || from == CurrentSchemaVersion {
+		return data, from, err
+	}
+	upgraded, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, from, fmt.Errorf("failed to encode migrated project: %w", err)
+	}
+	return upgraded, from, nil
+}
+
+// upgradeJSON is upgradeYAML for JSON exports.
+func upgradeJSON(data []byte) ([]byte, int, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if doc == nil {
+		return data, CurrentSchemaVersion, nil
+	}
+	from, err := migrateDocument(doc)
+	if err != nil || from == CurrentSchemaVersion {
+		return data, from, err
+	}
+	upgraded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, from, fmt.Errorf("failed to encode migrated project: %w", err)
+	}
+	return upgraded, from, nil
+}
+
+// backupBeforeUpgrade keeps the original of a project file about to be
+// upgraded in the backups directory next to it.
+func backupBeforeUpgrade(path string, data []byte, from int) error {
+	dir := filepath.Join(filepath.Dir(path), "backups")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	backupPath := filepath.Join(dir, fmt.Sprintf("%s_schema_v%d_%s%s", name, from, time.Now().Format("20060102_150405"), filepath.Ext(path)))
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to back up project before upgrade: %w", err)
+	}
+	return nil
+}
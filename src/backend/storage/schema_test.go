@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"elano.fr/src/backend/models"
+)
+
+// goldenProject is the project described by every testdata/schema_v*.yaml
+// file, each in the format of its schema version.
+var goldenProject = &models.Project{
+	SchemaVersion: CurrentSchemaVersion,
+	ID:            "5b0c7c2e-8f0e-4a51-9d67-1f6f3c2a9e10",
+	Name:          "Golden Project",
+	USBInterface:  "/dev/ttyUSB0",
+	Fixtures: []models.Fixture{{
+		ID:          "par-1",
+		Name:        "Par 1",
+		Description: "Front wash",
+		Type:        "par",
+		Channels: []models.FixtureChannel{
+			{Name: "red", Min: 0, Max: 255, ChannelAddress: 1},
+			{Name: "green", Min: 0, Max: 255, ChannelAddress: 2},
+			{Name: "blue", Min: 0, Max: 255, ChannelAddress: 3},
+		},
+	}},
+	Presets: []models.Preset{{
+		ID:   "warm",
+		Name: "Warm",
+		Channels: []models.ChannelValue{
+			{DMXAddress: 1, Value: 255},
+			{DMXAddress: 2, Value: 120},
+		},
+	}},
+	Shows: []models.Show{},
+}
+
+// TestLoadSchemaVersions loads the golden file of every schema version and
+// checks that it is upgraded to the current version, with a backup of the
+// original when it was older.
+func TestLoadSchemaVersions(t *testing.T) {
+	for version := 0; version <= CurrentSchemaVersion; version++ {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			original, err := os.ReadFile(filepath.Join("testdata", fmt.Sprintf("schema_v%d.yaml", version)))
+			if err != nil {
+				t.Fatalf("missing golden file for schema version %d: %v", version, err)
+			}
+			dir := t.TempDir()
+			path := filepath.Join(dir, "project.yaml")
+			if err := os.WriteFile(path, original, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			project, err := LoadProjectFromFile(path)
+			if err != nil {
+				t.Fatalf("LoadProjectFromFile: %v", err)
+			}
+			if !reflect.DeepEqual(project, goldenProject) {
+				t.Errorf("loaded project = %+v, want %+v", project, goldenProject)
+			}
+
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backups, err := filepath.Glob(filepath.Join(dir, "backups", fmt.Sprintf("project_schema_v%d_*.yaml", version)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version == CurrentSchemaVersion {
+				if !bytes.Equal(written, original) {
+					t.Errorf("current project file was rewritten")
+				}
+				if len(backups) != 0 {
+					t.Errorf("current project file was backed up: %v", backups)
+				}
+				return
+			}
+
+			if !strings.Contains(string(written), fmt.Sprintf("schema_version: %d\n", CurrentSchemaVersion)) {
+				t.Errorf("upgraded file does not have schema_version %d:\n%s", CurrentSchemaVersion, written)
+			}
+			if len(backups) != 1 {
+				t.Fatalf("got %d backups of the original, want 1", len(backups))
+			}
+			backup, err := os.ReadFile(backups[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(backup, original) {
+				t.Errorf("backup differs from the original file")
+			}
+
+			// The upgraded file loads as is.
+			reloaded, err := LoadProjectFromFile(path)
+			if err != nil {
+				t.Fatalf("loading the upgraded file: %v", err)
+			}
+			if !reflect.DeepEqual(reloaded, goldenProject) {
+				t.Errorf("reloaded project = %+v, want %+v", reloaded, goldenProject)
+			}
+		})
+	}
+}
+
+func TestLoadNewerSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.yaml")
+	data := fmt.Sprintf("schema_version: %d\nid: p\nname: P\nusb_interface: /dev/null\n", CurrentSchemaVersion+1)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadProjectFromFile(path); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("LoadProjectFromFile = %v, want a newer schema version error", err)
+	}
+}
+
+// The revision only lives in memory, so saving never changes it in the file.
+func TestSaveOmitsRevision(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "project.yaml")
+	project := *goldenProject
+	project.Revision = 7
+	if err := SaveProjectToFile(&project, path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "revision") {
+		t.Errorf("saved project holds its revision:\n%s", data)
+	}
+	loaded, err := LoadProjectFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Revision != 0 {
+		t.Errorf("loaded revision = %d, want 0", loaded.Revision)
+	}
+}
@@ -0,0 +1,782 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/models"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations upgrade the database schema one version at a time. The
+// schema version is the number of migrations applied; never edit a released
+// migration, append a new one instead.
+var sqliteMigrations = []string{
+	`CREATE TABLE project (
+		id            TEXT PRIMARY KEY,
+		name          TEXT NOT NULL,
+		usb_interface TEXT NOT NULL,
+		latitude      REAL,
+		longitude     REAL,
+		revision      INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE fixtures (
+		id          TEXT PRIMARY KEY,
+		position    INTEGER NOT NULL,
+		name        TEXT NOT NULL,
+		description TEXT NOT NULL,
+		type        TEXT NOT NULL
+	);
+	CREATE TABLE fixture_channels (
+		fixture_id           TEXT NOT NULL REFERENCES fixtures(id) ON DELETE CASCADE,
+		position             INTEGER NOT NULL,
+		name                 TEXT NOT NULL,
+		description          TEXT NOT NULL,
+		min                  INTEGER NOT NULL,
+		max                  INTEGER NOT NULL,
+		channel_address      INTEGER NOT NULL,
+		fine_channel_address INTEGER NOT NULL DEFAULT 0,
+		snap                 INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (fixture_id, position)
+	);
+	CREATE TABLE presets (
+		id          TEXT PRIMARY KEY,
+		position    INTEGER NOT NULL,
+		name        TEXT NOT NULL,
+		description TEXT NOT NULL
+	);
+	CREATE TABLE preset_channels (
+		preset_id   TEXT NOT NULL REFERENCES presets(id) ON DELETE CASCADE,
+		position    INTEGER NOT NULL,
+		dmx_address INTEGER NOT NULL,
+		value       INTEGER NOT NULL,
+		PRIMARY KEY (preset_id, position)
+	);
+	CREATE TABLE preset_palettes (
+		preset_id   TEXT NOT NULL REFERENCES presets(id) ON DELETE CASCADE,
+		position    INTEGER NOT NULL,
+		palette_id  TEXT NOT NULL,
+		fixture_ids TEXT NOT NULL,
+		PRIMARY KEY (preset_id, position)
+	);
+	CREATE TABLE shows (
+		id           TEXT PRIMARY KEY,
+		position     INTEGER NOT NULL,
+		name         TEXT NOT NULL,
+		timecode_fps INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE show_steps (
+		show_id   TEXT NOT NULL REFERENCES shows(id) ON DELETE CASCADE,
+		position  INTEGER NOT NULL,
+		preset_id TEXT NOT NULL,
+		duration  INTEGER NOT NULL,
+		fade_ms   INTEGER NOT NULL,
+		timecode  TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (show_id, position)
+	);
+	CREATE TABLE palettes (
+		id       TEXT PRIMARY KEY,
+		position INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		data     TEXT NOT NULL
+	);
+	CREATE TABLE schedules (
+		id       TEXT PRIMARY KEY,
+		position INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		data     TEXT NOT NULL
+	);
+	CREATE TABLE pixel_maps (
+		id       TEXT PRIMARY KEY,
+		position INTEGER NOT NULL,
+		name     TEXT NOT NULL,
+		data     TEXT NOT NULL
+	);`,
+	`CREATE INDEX show_steps_preset ON show_steps(preset_id);
+	CREATE TABLE change_log (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		time      TEXT NOT NULL,
+		revision  INTEGER NOT NULL,
+		entity    TEXT NOT NULL,
+		entity_id TEXT NOT NULL,
+		op        TEXT NOT NULL,
+		data      TEXT
+	);`,
+}
+
+// Palettes, schedules and pixel maps are stored as JSON documents; the other
+// entities are split into normalized tables.
+var sqliteDocumentTables = map[string]string{
+	"palette":   "palettes",
+	"schedule":  "schedules",
+	"pixel_map": "pixel_maps",
+}
+
+// SQLiteStore keeps a project in an SQLite database. Saves only write the
+// entities that changed, and every change is appended to an audit log.
+type SQLiteStore struct {
+	mu      sync.RWMutex
+	db      *sql.DB
+	project *models.Project
+	path    string
+	backups *backupSet
+	listeners
+}
+
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	project, err := loadSQLite(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	backups, err := newBackupSet(filepath.Join(filepath.Dir(path), "backups"), ".db")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if project != nil {
+		warnDanglingReferences(path, project)
+	}
+	return &SQLiteStore{db: db, project: project, path: path, backups: backups}, nil
+}
+
+// openSQLite opens a project database and brings its schema up to date.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %q: %w", path, err)
+	}
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// loadSQLiteProject reads the project of a database, such as a backup,
+// without opening a store on it.
+func loadSQLiteProject(path string) (*models.Project, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	project, err := loadSQLite(db)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, fmt.Errorf("database %q holds no project", path)
+	}
+	return project, nil
+}
+
+// MigrateYAMLToSQLite copies the project file at yamlPath into a new database
+// at dbPath, keeping its revision. It refuses to overwrite a database that
+// already holds a project.
+func MigrateYAMLToSQLite(yamlPath, dbPath string) error {
+	project, err := LoadProjectFromFile(yamlPath)
+	if err != nil {
+		return err
+	}
+	if err := validateProject(project); err != nil {
+		return fmt.Errorf("project validation failed: %w", err)
+	}
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if store.project != nil {
+		return fmt.Errorf("database %q already holds a project", dbPath)
+	}
+
+	tx, err := store.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := writeProjectRow(tx, project, true); err != nil {
+		return err
+	}
+	changes := DiffProjects(nil, project)
+	if err := writeChanges(tx, changes); err != nil {
+		return err
+	}
+	if err := logChanges(tx, project.Revision, changes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func migrateSQLite(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", version, len(sqliteMigrations))
+	}
+	for v := version; v < len(sqliteMigrations); v++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[v]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to migrate database to version %d: %w", v+1, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, v+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record schema version %d: %w", v+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to migrate database to version %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Get() *models.Project {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.project == nil {
+		return nil
+	}
+	return copyProject(s.project)
+}
+
+func (s *SQLiteStore) Save(p *models.Project) error {
+	if p == nil {
+		return fmt.Errorf("cannot save nil project")
+	}
+
+	defer s.flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.project != nil && p.Revision != s.project.Revision {
+		return fmt.Errorf("%w: expected revision %d, current is %d", ErrRevisionConflict, p.Revision, s.project.Revision)
+	}
+	if err := validateProject(p); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidProject, err)
+	}
+	if err := checkReferences(s.project, p); err != nil {
+		return fmt.Errorf("project validation failed: %w", err)
+	}
+
+	if s.project != nil && s.backups.due(time.Now()) {
+		if err := s.createBackup(BackupManual); err != nil {
+			fmt.Printf("Warning: failed to create backup: %v\n", err)
+		}
+	}
+
+	before := s.project
+	changes := DiffProjects(before, p)
+
+	revision := p.Revision
+	p.Revision++
+	if err := s.write(before, p, changes); err != nil {
+		p.Revision = revision
+		return fmt.Errorf("failed to save project: %w", err)
+	}
+
+	s.project = p
+	s.notify(changes)
+	return nil
+}
+
+func (s *SQLiteStore) write(before, after *models.Project, changes []Change) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := writeProjectRow(tx, after, before == nil); err != nil {
+		return err
+	}
+	if err := writeChanges(tx, changes); err != nil {
+		return err
+	}
+	if err := writePositions(tx, before, after); err != nil {
+		return err
+	}
+	if err := logChanges(tx, after.Revision, changes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Delete() error {
+	defer s.flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.project != nil {
+		if err := s.createBackup(BackupDeleted); err != nil {
+			fmt.Printf("Warning: failed to create backup: %v\n", err)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"project", "fixtures", "presets", "shows", "palettes", "schedules", "pixel_maps"} {
+		if _, err := tx.Exec(`DELETE FROM ` + table); err != nil {
+			return fmt.Errorf("failed to delete project: %w", err)
+		}
+	}
+	changes := DiffProjects(s.project, nil)
+	if s.project != nil {
+		if err := logChanges(tx, s.project.Revision, changes); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to delete project: %w", err)
+	}
+
+	s.notify(changes)
+	s.project = nil
+	return nil
+}
+
+func (s *SQLiteStore) Backup() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.project == nil {
+		return fmt.Errorf("no project to backup")
+	}
+	return s.createBackup(BackupManual)
+}
+
+func (s *SQLiteStore) Backups() ([]BackupInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.list()
+}
+
+func (s *SQLiteStore) LoadBackup(name string) (*models.Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.load(name)
+}
+
+func (s *SQLiteStore) BackupFile(name string) (string, error) {
+	return s.backups.file(name)
+}
+
+func (s *SQLiteStore) BackupPolicy() BackupPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backups.policy
+}
+
+func (s *SQLiteStore) SetBackupPolicy(policy BackupPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backups.policy = policy
+}
+
+func (s *SQLiteStore) GetPath() string {
+	return s.path
+}
+
+// createBackup copies the database with VACUUM INTO, which produces a
+// consistent snapshot without blocking readers.
+func (s *SQLiteStore) createBackup(kind string) error {
+	backupPath := s.backups.path(kind)
+	if _, err := s.db.Exec(`VACUUM INTO ?`, backupPath); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	s.backups.taken(kind)
+	return nil
+}
+
+// loadSQLite reads the project back from the normalized tables, or returns
+// nil when the database is empty.
+func loadSQLite(db *sql.DB) (*models.Project, error) {
+	p := &models.Project{SchemaVersion: CurrentSchemaVersion}
+	var lat, lon sql.NullFloat64
+	err := db.QueryRow(`SELECT id, name, usb_interface, latitude, longitude, revision FROM project`).
+		Scan(&p.ID, &p.Name, &p.USBInterface, &lat, &lon, &p.Revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project: %w", err)
+	}
+	if lat.Valid && lon.Valid {
+		p.Location = &models.Location{Latitude: lat.Float64, Longitude: lon.Float64}
+	}
+
+	if p.Fixtures, err = loadFixtures(db); err != nil {
+		return nil, err
+	}
+	if p.Presets, err = loadPresets(db); err != nil {
+		return nil, err
+	}
+	if p.Shows, err = loadShows(db); err != nil {
+		return nil, err
+	}
+	if err := loadDocuments(db, "palettes", &p.Palettes); err != nil {
+		return nil, err
+	}
+	if err := loadDocuments(db, "schedules", &p.Schedules); err != nil {
+		return nil, err
+	}
+	if err := loadDocuments(db, "pixel_maps", &p.PixelMaps); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func loadFixtures(db *sql.DB) ([]models.Fixture, error) {
+	fixtures := []models.Fixture{}
+	index := make(map[string]int)
+	rows, err := db.Query(`SELECT id, name, description, type FROM fixtures ORDER BY position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f models.Fixture
+		if err := rows.Scan(&f.ID, &f.Name, &f.Description, &f.Type); err != nil {
+			return nil, fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		index[f.ID] = len(fixtures)
+		fixtures = append(fixtures, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fixtures: %w", err)
+	}
+
+	chRows, err := db.Query(`SELECT fixture_id, name, description, min, max, channel_address, fine_channel_address, snap
+		FROM fixture_channels ORDER BY fixture_id, position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture channels: %w", err)
+	}
+	defer chRows.Close()
+	for chRows.Next() {
+		var id string
+		var ch models.FixtureChannel
+		if err := chRows.Scan(&id, &ch.Name, &ch.Description, &ch.Min, &ch.Max, &ch.ChannelAddress, &ch.FineChannelAddress, &ch.Snap); err != nil {
+			return nil, fmt.Errorf("failed to read fixture channels: %w", err)
+		}
+		if i, ok := index[id]; ok {
+			fixtures[i].Channels = append(fixtures[i].Channels, ch)
+		}
+	}
+	return fixtures, chRows.Err()
+}
+
+func loadPresets(db *sql.DB) ([]models.Preset, error) {
+	presets := []models.Preset{}
+	index := make(map[string]int)
+	rows, err := db.Query(`SELECT id, name, description FROM presets ORDER BY position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pr models.Preset
+		if err := rows.Scan(&pr.ID, &pr.Name, &pr.Description); err != nil {
+			return nil, fmt.Errorf("failed to read presets: %w", err)
+		}
+		index[pr.ID] = len(presets)
+		presets = append(presets, pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read presets: %w", err)
+	}
+
+	chRows, err := db.Query(`SELECT preset_id, dmx_address, value FROM preset_channels ORDER BY preset_id, position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preset channels: %w", err)
+	}
+	defer chRows.Close()
+	for chRows.Next() {
+		var id string
+		var ch models.ChannelValue
+		if err := chRows.Scan(&id, &ch.DMXAddress, &ch.Value); err != nil {
+			return nil, fmt.Errorf("failed to read preset channels: %w", err)
+		}
+		if i, ok := index[id]; ok {
+			presets[i].Channels = append(presets[i].Channels, ch)
+		}
+	}
+	if err := chRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read preset channels: %w", err)
+	}
+
+	refRows, err := db.Query(`SELECT preset_id, palette_id, fixture_ids FROM preset_palettes ORDER BY preset_id, position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preset palettes: %w", err)
+	}
+	defer refRows.Close()
+	for refRows.Next() {
+		var id, fixtureIDs string
+		var ref models.PaletteRef
+		if err := refRows.Scan(&id, &ref.PaletteID, &fixtureIDs); err != nil {
+			return nil, fmt.Errorf("failed to read preset palettes: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fixtureIDs), &ref.FixtureIDs); err != nil {
+			return nil, fmt.Errorf("failed to decode palette fixtures of preset %s: %w", id, err)
+		}
+		if i, ok := index[id]; ok {
+			presets[i].Palettes = append(presets[i].Palettes, ref)
+		}
+	}
+	return presets, refRows.Err()
+}
+
+func loadShows(db *sql.DB) ([]models.Show, error) {
+	shows := []models.Show{}
+	index := make(map[string]int)
+	rows, err := db.Query(`SELECT id, name, timecode_fps FROM shows ORDER BY position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shows: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s models.Show
+		if err := rows.Scan(&s.ID, &s.Name, &s.TimecodeFPS); err != nil {
+			return nil, fmt.Errorf("failed to read shows: %w", err)
+		}
+		index[s.ID] = len(shows)
+		shows = append(shows, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shows: %w", err)
+	}
+
+	stepRows, err := db.Query(`SELECT show_id, preset_id, duration, fade_ms, timecode FROM show_steps ORDER BY show_id, position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read show steps: %w", err)
+	}
+	defer stepRows.Close()
+	for stepRows.Next() {
+		var id string
+		var step models.ShowStep
+		if err := stepRows.Scan(&id, &step.PresetID, &step.Duration, &step.FadeMS, &step.Timecode); err != nil {
+			return nil, fmt.Errorf("failed to read show steps: %w", err)
+		}
+		if i, ok := index[id]; ok {
+			shows[i].Steps = append(shows[i].Steps, step)
+		}
+	}
+	return shows, stepRows.Err()
+}
+
+func loadDocuments[T any](db *sql.DB, table string, out *[]T) error {
+	rows, err := db.Query(`SELECT data FROM ` + table + ` ORDER BY position`)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		var item T
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", table, err)
+		}
+		*out = append(*out, item)
+	}
+	return rows.Err()
+}
+
+func writeProjectRow(tx *sql.Tx, p *models.Project, insert bool) error {
+	var lat, lon sql.NullFloat64
+	if p.Location != nil {
+		lat = sql.NullFloat64{Float64: p.Location.Latitude, Valid: true}
+		lon = sql.NullFloat64{Float64: p.Location.Longitude, Valid: true}
+	}
+	query := `UPDATE project SET id = ?, name = ?, usb_interface = ?, latitude = ?, longitude = ?, revision = ?`
+	if insert {
+		query = `INSERT INTO project (id, name, usb_interface, latitude, longitude, revision) VALUES (?, ?, ?, ?, ?, ?)`
+	}
+	if _, err := tx.Exec(query, p.ID, p.Name, p.USBInterface, lat, lon, p.Revision); err != nil {
+		return fmt.Errorf("failed to write project: %w", err)
+	}
+	return nil
+}
+
+// writeChanges replaces the rows of every changed entity. Project settings
+// are written by writeProjectRow.
+func writeChanges(tx *sql.Tx, changes []Change) error {
+	for _, c := range changes {
+		if c.Entity == "project" {
+			continue
+		}
+		if c.Op != OpCreate {
+			if err := deleteEntity(tx, c.Entity, c.ID); err != nil {
+				return err
+			}
+		}
+		if c.Op != OpDelete {
+			if err := insertEntity(tx, c.Entity, c.Index, c.After); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deleteEntity(tx *sql.Tx, entity, id string) error {
+	table, ok := sqliteDocumentTables[entity]
+	if !ok {
+		table = entity + "s"
+	}
+	if _, err := tx.Exec(`DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", entity, id, err)
+	}
+	return nil
+}
+
+func insertEntity(tx *sql.Tx, entity string, position int, raw json.RawMessage) error {
+	if table, ok := sqliteDocumentTables[entity]; ok {
+		var doc struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", entity, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO `+table+` (id, position, name, data) VALUES (?, ?, ?, ?)`, doc.ID, position, doc.Name, string(raw)); err != nil {
+			return fmt.Errorf("failed to write %s %s: %w", entity, doc.ID, err)
+		}
+		return nil
+	}
+
+	switch entity {
+	case "fixture":
+		var f models.Fixture
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return fmt.Errorf("failed to decode fixture: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO fixtures (id, position, name, description, type) VALUES (?, ?, ?, ?, ?)`,
+			f.ID, position, f.Name, f.Description, f.Type); err != nil {
+			return fmt.Errorf("failed to write fixture %s: %w", f.ID, err)
+		}
+		for i, ch := range f.Channels {
+			if _, err := tx.Exec(`INSERT INTO fixture_channels (fixture_id, position, name, description, min, max, channel_address, fine_channel_address, snap)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				f.ID, i, ch.Name, ch.Description, ch.Min, ch.Max, ch.ChannelAddress, ch.FineChannelAddress, ch.Snap); err != nil {
+				return fmt.Errorf("failed to write fixture %s channel %d: %w", f.ID, i, err)
+			}
+		}
+	case "preset":
+		var pr models.Preset
+		if err := json.Unmarshal(raw, &pr); err != nil {
+			return fmt.Errorf("failed to decode preset: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO presets (id, position, name, description) VALUES (?, ?, ?, ?)`,
+			pr.ID, position, pr.Name, pr.Description); err != nil {
+			return fmt.Errorf("failed to write preset %s: %w", pr.ID, err)
+		}
+		for i, ch := range pr.Channels {
+			if _, err := tx.Exec(`INSERT INTO preset_channels (preset_id, position, dmx_address, value) VALUES (?, ?, ?, ?)`,
+				pr.ID, i, ch.DMXAddress, ch.Value); err != nil {
+				return fmt.Errorf("failed to write preset %s channel %d: %w", pr.ID, i, err)
+			}
+		}
+		for i, ref := range pr.Palettes {
+			fixtureIDs, _ := json.Marshal(ref.FixtureIDs)
+			if _, err := tx.Exec(`INSERT INTO preset_palettes (preset_id, position, palette_id, fixture_ids) VALUES (?, ?, ?, ?)`,
+				pr.ID, i, ref.PaletteID, string(fixtureIDs)); err != nil {
+				return fmt.Errorf("failed to write preset %s palette %d: %w", pr.ID, i, err)
+			}
+		}
+	case "show":
+		var s models.Show
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("failed to decode show: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO shows (id, position, name, timecode_fps) VALUES (?, ?, ?, ?)`,
+			s.ID, position, s.Name, s.TimecodeFPS); err != nil {
+			return fmt.Errorf("failed to write show %s: %w", s.ID, err)
+		}
+		for i, step := range s.Steps {
+			if _, err := tx.Exec(`INSERT INTO show_steps (show_id, position, preset_id, duration, fade_ms, timecode) VALUES (?, ?, ?, ?, ?, ?)`,
+				s.ID, i, step.PresetID, step.Duration, step.FadeMS, step.Timecode); err != nil {
+				return fmt.Errorf("failed to write show %s step %d: %w", s.ID, i, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown entity type %q", entity)
+	}
+	return nil
+}
+
+// writePositions renumbers the entities of every list whose order changed,
+// including by insertions and deletions.
+func writePositions(tx *sql.Tx, before, after *models.Project) error {
+	for _, kind := range entityKinds {
+		if kind.name == "project" {
+			continue
+		}
+		var beforeIDs []string
+		if before != nil {
+			beforeIDs, _ = kind.items(before)
+		}
+		afterIDs, _ := kind.items(after)
+		if slices.Equal(beforeIDs, afterIDs) {
+			continue
+		}
+		table, ok := sqliteDocumentTables[kind.name]
+		if !ok {
+			table = kind.name + "s"
+		}
+		for i, id := range afterIDs {
+			if _, err := tx.Exec(`UPDATE `+table+` SET position = ? WHERE id = ?`, i, id); err != nil {
+				return fmt.Errorf("failed to order %s: %w", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+func logChanges(tx *sql.Tx, revision int64, changes []Change) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, c := range changes {
+		data := c.After
+		if c.Op == OpDelete {
+			data = c.Before
+		}
+		if _, err := tx.Exec(`INSERT INTO change_log (time, revision, entity, entity_id, op, data) VALUES (?, ?, ?, ?, ?, ?)`,
+			now, revision, c.Entity, c.ID, c.Op, string(data)); err != nil {
+			return fmt.Errorf("failed to write change log: %w", err)
+		}
+	}
+	return nil
+}
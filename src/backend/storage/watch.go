@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"elano.fr/src/backend/models"
+)
+
+// ErrModifiedOnDisk is returned by Save when the project file was changed by
+// another program since the store last read or wrote it. The store refuses to
+// overwrite it until the file has been reloaded.
+var ErrModifiedOnDisk = errors.New("project file was modified on disk")
+
+// Reloader is implemented by stores whose files can be edited outside the
+// server, by hand or by a git pull.
+type Reloader interface {
+	// Reload reads the project again if its files changed since the store
+	// last read or wrote them, and returns what changed. Files that fail to
+	// decode or validate leave the project untouched.
+	Reload() ([]Change, error)
+}
+
+// fileState identifies the content of a file as the store last saw it.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+func readFileState(path string) (fileState, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileState{}, nil, err
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, nil, err
+	}
+	return fileState{modTime: st.ModTime(), size: st.Size(), hash: sha256.Sum256(data)}, data, nil
+}
+
+// changed reports whether the file at path no longer has the content of f,
+// along with its current state and content. The file is only read when its
+// modification time or size differ. A missing file has not changed, so that
+// saving recreates it.
+func (f fileState) changed(path string) (bool, fileState, []byte, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, f, nil, nil
+		}
+		return false, f, nil, err
+	}
+	if st.ModTime().Equal(f.modTime) && st.Size() == f.size {
+		return false, f, nil, nil
+	}
+	state, data, err := readFileState(path)
+	if err != nil {
+		return false, f, nil, err
+	}
+	if state.hash == f.hash {
+		return false, state, nil, nil
+	}
+	return true, state, data, nil
+}
+
+// decodeReloaded decodes a project file changed on disk. Unlike
+// LoadProjectFromFile, it never writes the file back: a file from an older
+// schema is upgraded in memory only.
+func decodeReloaded(data []byte) (*models.Project, error) {
+	if len(data) > maxProjectFileSize {
+		return nil, fmt.Errorf("project file too large: %d bytes (max %d)", len(data), maxProjectFileSize)
+	}
+	upgraded, _, err := upgradeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	project, err := decodeProjectYAML(upgraded)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProject(project); err != nil {
+		return nil, fmt.Errorf("invalid project: %w", err)
+	}
+	return project, nil
+}
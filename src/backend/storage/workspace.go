@@ -0,0 +1,517 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/models"
+	"github.com/google/uuid"
+)
+
+const (
+	FormatYAML      = "yaml"
+	FormatSQLite    = "sqlite"
+	FormatDirectory = "directory"
+)
+
+const (
+	workspaceProjectFile = "project.yaml"
+	workspaceDatabase    = "project.db"
+	workspaceArchiveDir  = "archive"
+	workspaceActiveFile  = "active"
+	workspaceHistorySize = 100
+)
+
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	ErrProjectActive   = errors.New("the active project cannot be archived")
+)
+
+// ProjectHistory is a project store that can undo and redo its saves.
+type ProjectHistory interface {
+	ProjectStore
+	Undo() (*HistoryEntry, error)
+	Redo() (*HistoryEntry, error)
+	History() (undo, redo []HistoryEntry)
+}
+
+type ProjectInfo struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	USBInterface string    `json:"usb_interface"`
+	Archived     bool      `json:"archived"`
+	Active       bool      `json:"active"`
+	Modified     time.Time `json:"modified"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Workspace is a library of projects, one directory per project, of which
+// one is active. It implements ProjectHistory by delegating to the active
+// project, so the rest of the server is unaware of switches.
+type Workspace struct {
+	mu        sync.RWMutex
+	dir       string
+	format    string
+	policy    BackupPolicy
+	store     ProjectStore
+	activeID  string
+	active    *HistoryStore
+	listeners []ChangeListener
+}
+
+// OpenWorkspace opens the project library in dir. An empty library is seeded
+// with the project at legacyPath when it exists, or with a default project.
+// format selects how projects are stored when they are opened: with
+// FormatSQLite or FormatDirectory, a project still in a single YAML file is
+// migrated once. Projects already in a database or a directory stay there
+// whatever the format.
+func OpenWorkspace(dir, legacyPath, format string) (*Workspace, error) {
+	if format != FormatYAML && format != FormatSQLite && format != FormatDirectory {
+		return nil, fmt.Errorf("unknown project format %q", format)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, workspaceArchiveDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create workspace directory: %w", err)
+	}
+	w := &Workspace{dir: dir, format: format, policy: DefaultBackupPolicy()}
+
+	ids, err := w.projectIDs(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		project, err := LoadProjectFromFile(legacyPath)
+		if err != nil {
+			project = newProject("Default Project", "/dev/ttyUSB0")
+		}
+		if err := w.write(project, false); err != nil {
+			return nil, err
+		}
+		ids = []string{project.ID}
+	}
+
+	activeID := ids[0]
+	if data, err := os.ReadFile(filepath.Join(dir, workspaceActiveFile)); err == nil {
+		if id := strings.TrimSpace(string(data)); w.exists(id, false) {
+			activeID = id
+		}
+	}
+	if _, err := w.Switch(activeID); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func newProject(name, usbInterface string) *models.Project {
+	return &models.Project{
+		ID:           uuid.New().String(),
+		Name:         name,
+		USBInterface: usbInterface,
+		Fixtures:     []models.Fixture{},
+		Presets:      []models.Preset{},
+		Shows:        []models.Show{},
+	}
+}
+
+func (w *Workspace) projectDir(id string, archived bool) string {
+	if archived {
+		return filepath.Join(w.dir, workspaceArchiveDir, id)
+	}
+	return filepath.Join(w.dir, id)
+}
+
+func (w *Workspace) projectPath(id string, archived bool) string {
+	return filepath.Join(w.projectDir(id, archived), workspaceProjectFile)
+}
+
+func (w *Workspace) databasePath(id string, archived bool) string {
+	return filepath.Join(w.projectDir(id, archived), workspaceDatabase)
+}
+
+func (w *Workspace) exists(id string, archived bool) bool {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." || id == workspaceArchiveDir {
+		return false
+	}
+	return fileExists(w.projectPath(id, archived)) || fileExists(w.databasePath(id, archived))
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// load reads a project that is not active, from its database if it has one.
+func (w *Workspace) load(id string, archived bool) (*models.Project, error) {
+	if db := w.databasePath(id, archived); fileExists(db) {
+		store, err := NewSQLiteStore(db)
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+		if project := store.Get(); project != nil {
+			return project, nil
+		}
+		return nil, fmt.Errorf("database %q holds no project", db)
+	}
+	if dir := w.projectDir(id, archived); IsProjectDirectory(dir) {
+		return loadProjectDirectory(dir)
+	}
+	return LoadProjectFromFile(w.projectPath(id, archived))
+}
+
+func (w *Workspace) projectIDs(archived bool) ([]string, error) {
+	dir := w.dir
+	if archived {
+		dir = filepath.Join(w.dir, workspaceArchiveDir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() && w.exists(e.Name(), archived) {
+			ids = append(ids, e.Name())
+		}
+	}
+	return ids, nil
+}
+
+func (w *Workspace) write(p *models.Project, archived bool) error {
+	if err := os.MkdirAll(w.projectDir(p.ID, archived), 0755); err != nil {
+		return fmt.Errorf("failed to create project directory: %w", err)
+	}
+	return SaveProjectToFile(p, w.projectPath(p.ID, archived))
+}
+
+// List returns every project of the library, active ones first, then
+// archived ones, each sorted by name.
+func (w *Workspace) List() ([]ProjectInfo, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var infos []ProjectInfo
+	for _, archived := range []bool{false, true} {
+		ids, err := w.projectIDs(archived)
+		if err != nil {
+			return nil, err
+		}
+		var group []ProjectInfo
+		for _, id := range ids {
+			info := ProjectInfo{ID: id, Archived: archived, Active: !archived && id == w.activeID}
+			path := w.projectPath(id, archived)
+			if db := w.databasePath(id, archived); fileExists(db) {
+				path = db
+			}
+			if st, err := os.Stat(path); err == nil {
+				info.Modified = st.ModTime()
+			}
+			if project, err := w.load(id, archived); err != nil {
+				info.Error = err.Error()
+			} else {
+				info.Name = project.Name
+				info.USBInterface = project.USBInterface
+			}
+			group = append(group, info)
+		}
+		sort.Slice(group, func(i, j int) bool { return strings.ToLower(group[i].Name) < strings.ToLower(group[j].Name) })
+		infos = append(infos, group...)
+	}
+	return infos, nil
+}
+
+func (w *Workspace) Create(name, usbInterface string) (*ProjectInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	project := newProject(name, usbInterface)
+	if err := w.write(project, false); err != nil {
+		return nil, err
+	}
+	return &ProjectInfo{ID: project.ID, Name: project.Name, USBInterface: project.USBInterface, Modified: time.Now()}, nil
+}
+
+// Duplicate copies a project, active or archived, into a new project.
+func (w *Workspace) Duplicate(id, name string) (*ProjectInfo, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var project *models.Project
+	if id == w.activeID {
+		project = w.active.Get()
+	} else {
+		archived := w.exists(id, true)
+		if !archived && !w.exists(id, false) {
+			return nil, ErrProjectNotFound
+		}
+		var err error
+		if project, err = w.load(id, archived); err != nil {
+			return nil, err
+		}
+	}
+	if name == "" {
+		name = project.Name + " (copy)"
+	}
+	project.ID = uuid.New().String()
+	project.Name = name
+	project.Revision = 0
+	if err := w.write(project, false); err != nil {
+		return nil, err
+	}
+	return &ProjectInfo{ID: project.ID, Name: project.Name, USBInterface: project.USBInterface, Modified: time.Now()}, nil
+}
+
+// Rename changes the name of a project. The active project is renamed through
+// its store so the change is recorded in its history.
+func (w *Workspace) Rename(id, name string) error {
+	w.mu.RLock()
+	active, activeID := w.active, w.activeID
+	w.mu.RUnlock()
+	if id == activeID {
+		project := active.Get()
+		project.Name = name
+		return active.Save(project)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	archived := w.exists(id, true)
+	if !archived && !w.exists(id, false) {
+		return ErrProjectNotFound
+	}
+	if db := w.databasePath(id, archived); fileExists(db) {
+		store, err := NewSQLiteStore(db)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		project := store.Get()
+		if project == nil {
+			return fmt.Errorf("database %q holds no project", db)
+		}
+		project.Name = name
+		return store.Save(project)
+	}
+	if dir := w.projectDir(id, archived); IsProjectDirectory(dir) {
+		store, err := NewDirStore(dir)
+		if err != nil {
+			return err
+		}
+		project := store.Get()
+		project.Name = name
+		return store.Save(project)
+	}
+	project, err := LoadProjectFromFile(w.projectPath(id, archived))
+	if err != nil {
+		return err
+	}
+	project.Name = name
+	return SaveProjectToFile(project, w.projectPath(id, archived))
+}
+
+func (w *Workspace) Archive(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if id == w.activeID {
+		return ErrProjectActive
+	}
+	if !w.exists(id, false) {
+		return ErrProjectNotFound
+	}
+	return os.Rename(w.projectDir(id, false), w.projectDir(id, true))
+}
+
+func (w *Workspace) Restore(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.exists(id, true) {
+		return ErrProjectNotFound
+	}
+	return os.Rename(w.projectDir(id, true), w.projectDir(id, false))
+}
+
+// Switch makes another project of the library active and returns it.
+func (w *Workspace) Switch(id string) (*models.Project, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.exists(id, false) {
+		return nil, ErrProjectNotFound
+	}
+	store, err := w.open(id)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := w.store.(io.Closer); ok {
+		closer.Close()
+	}
+	store.OnChange(w.notify)
+	if backups, ok := store.(BackupManager); ok {
+		backups.SetBackupPolicy(w.policy)
+	}
+	w.store = store
+	w.active = NewHistoryStore(store, workspaceHistorySize)
+	w.activeID = id
+	if err := os.WriteFile(filepath.Join(w.dir, workspaceActiveFile), []byte(id+"\n"), 0644); err != nil {
+		fmt.Printf("Warning: failed to remember active project: %v\n", err)
+	}
+	return w.active.Get(), nil
+}
+
+// open opens the store of a project, first migrating a single project.yaml
+// to a database when the workspace uses FormatSQLite, or to the directory
+// layout with FormatDirectory. The migrated YAML file is kept aside as
+// project.yaml.migrated.
+func (w *Workspace) open(id string) (ProjectStore, error) {
+	db := w.databasePath(id, false)
+	dir := w.projectDir(id, false)
+	if !fileExists(db) && IsProjectDirectory(dir) {
+		return NewDirStore(dir)
+	}
+	if !fileExists(db) && w.format == FormatDirectory {
+		if err := MigrateYAMLToDirectory(w.projectPath(id, false)); err != nil {
+			return nil, fmt.Errorf("failed to migrate project to a directory: %w", err)
+		}
+		return NewDirStore(dir)
+	}
+	if !fileExists(db) && w.format == FormatSQLite {
+		yamlPath := w.projectPath(id, false)
+		if err := MigrateYAMLToSQLite(yamlPath, db); err != nil {
+			os.Remove(db)
+			return nil, fmt.Errorf("failed to migrate project to SQLite: %w", err)
+		}
+		if err := os.Rename(yamlPath, yamlPath+".migrated"); err != nil {
+			return nil, fmt.Errorf("failed to set aside migrated project file: %w", err)
+		}
+	}
+	if fileExists(db) {
+		return NewSQLiteStore(db)
+	}
+	return NewYAMLStore(w.projectPath(id, false))
+}
+
+func (w *Workspace) ActiveID() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.activeID
+}
+
+func (w *Workspace) notify(changes []Change) {
+	w.mu.RLock()
+	listeners := w.listeners
+	w.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(changes)
+	}
+}
+
+func (w *Workspace) current() *HistoryStore {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.active
+}
+
+func (w *Workspace) Get() *models.Project                 { return w.current().Get() }
+func (w *Workspace) Save(p *models.Project) error         { return w.current().Save(p) }
+func (w *Workspace) Delete() error                        { return w.current().Delete() }
+func (w *Workspace) Backup() error                        { return w.current().Backup() }
+func (w *Workspace) GetPath() string                      { return w.current().GetPath() }
+func (w *Workspace) Undo() (*HistoryEntry, error)         { return w.current().Undo() }
+func (w *Workspace) Redo() (*HistoryEntry, error)         { return w.current().Redo() }
+func (w *Workspace) History() (undo, redo []HistoryEntry) { return w.current().History() }
+
+// Watch polls the files of the active project every interval and reloads
+// them when they change outside the server. onReload is called after every
+// reload that changed the project or failed. Calling the returned function
+// stops watching. A zero interval disables watching.
+func (w *Workspace) Watch(interval time.Duration, onReload func([]Change, error)) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				changes, err := w.Reload()
+				if err != nil || len(changes) > 0 {
+					onReload(changes, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Reload reloads the active project if its store supports it.
+func (w *Workspace) Reload() ([]Change, error) {
+	return w.current().Reload()
+}
+
+func (w *Workspace) OnChange(fn ChangeListener) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+func (w *Workspace) backups() (BackupManager, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if backups, ok := w.store.(BackupManager); ok {
+		return backups, nil
+	}
+	return nil, fmt.Errorf("the active project store does not keep backups")
+}
+
+func (w *Workspace) Backups() ([]BackupInfo, error) {
+	backups, err := w.backups()
+	if err != nil {
+		return nil, err
+	}
+	return backups.Backups()
+}
+
+func (w *Workspace) LoadBackup(name string) (*models.Project, error) {
+	backups, err := w.backups()
+	if err != nil {
+		return nil, err
+	}
+	return backups.LoadBackup(name)
+}
+
+func (w *Workspace) BackupFile(name string) (string, error) {
+	backups, err := w.backups()
+	if err != nil {
+		return "", err
+	}
+	return backups.BackupFile(name)
+}
+
+func (w *Workspace) BackupPolicy() BackupPolicy {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.policy
+}
+
+// SetBackupPolicy applies policy to the active project and to every project
+// switched to later.
+func (w *Workspace) SetBackupPolicy(policy BackupPolicy) {
+	w.mu.Lock()
+	w.policy = policy
+	w.mu.Unlock()
+	if backups, err := w.backups(); err == nil {
+		backups.SetBackupPolicy(policy)
+	}
+}
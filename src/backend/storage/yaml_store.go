@@ -1,15 +1,24 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/palette"
+	"elano.fr/src/backend/pixelmap"
+	"elano.fr/src/backend/scheduler"
+	"elano.fr/src/backend/timecode"
 	"gopkg.in/yaml.v3"
 )
 
+const maxProjectFileSize = 10 * 1024 * 1024
+
 func SaveProjectToFile(project *models.Project, path string) error {
 	if project == nil {
 		return fmt.Errorf("cannot save nil project")
@@ -26,6 +35,7 @@ func SaveProjectToFile(project *models.Project, path string) error {
 	}
 	defer f.Close()
 
+	project.SchemaVersion = CurrentSchemaVersion
 	encoder := yaml.NewEncoder(f)
 	encoder.SetIndent(2)
 	defer encoder.Close()
@@ -55,30 +65,72 @@ func LoadProjectFromFile(path string) (*models.Project, error) {
 		return nil, fmt.Errorf("failed to stat file %q: %w", path, err)
 	}
 
-	const maxFileSize = 10 * 1024 * 1024
-	if info.Size() > maxFileSize {
-		return nil, fmt.Errorf("project file too large: %d bytes (max %d)", info.Size(), maxFileSize)
+	if info.Size() > maxProjectFileSize {
+		return nil, fmt.Errorf("project file too large: %d bytes (max %d)", info.Size(), maxProjectFileSize)
 	}
 
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %q: %w", path, err)
+		return nil, fmt.Errorf("failed to read file %q: %w", path, err)
 	}
-	defer f.Close()
 
+	upgraded, from, err := upgradeYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", path, err)
+	}
+	project, err := decodeProjectYAML(upgraded)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", path, err)
+	}
+
+	if from < CurrentSchemaVersion {
+		if err := backupBeforeUpgrade(path, data, from); err != nil {
+			return nil, err
+		}
+		tempFile := path + ".tmp"
+		if err := SaveProjectToFile(project, tempFile); err != nil {
+			return nil, fmt.Errorf("failed to write upgraded project: %w", err)
+		}
+		if err := os.Rename(tempFile, path); err != nil {
+			os.Remove(tempFile)
+			return nil, fmt.Errorf("failed to write upgraded project: %w", err)
+		}
+		fmt.Printf("Upgraded %s from schema version %d to %d\n", path, from, CurrentSchemaVersion)
+	}
+	return project, nil
+}
+
+// DecodeProjectYAML reads a project file, upgrading it from older schema
+// versions, rejecting unknown fields, and validates it.
+func DecodeProjectYAML(r io.Reader) (*models.Project, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxProjectFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project: %w", err)
+	}
+	if len(data) > maxProjectFileSize {
+		return nil, fmt.Errorf("project file too large (max %d bytes)", maxProjectFileSize)
+	}
+	upgraded, _, err := upgradeYAML(data)
+	if err != nil {
+		return nil, err
+	}
+	return decodeProjectYAML(upgraded)
+}
+
+func decodeProjectYAML(data []byte) (*models.Project, error) {
 	var project models.Project
-	decoder := yaml.NewDecoder(f)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
 	decoder.KnownFields(true)
 
 	if err := decoder.Decode(&project); err != nil {
 		if err == io.EOF {
-			return nil, fmt.Errorf("project file is empty: %q", path)
+			return nil, fmt.Errorf("project file is empty")
 		}
-		return nil, fmt.Errorf("failed to decode YAML from %q: %w", path, err)
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
 	}
 
-	if err := validateLoadedProject(&project); err != nil {
-		return nil, fmt.Errorf("invalid project in %q: %w", path, err)
+	if err := validateContent(&project); err != nil {
+		return nil, fmt.Errorf("invalid project: %w", err)
 	}
 
 	if project.Fixtures == nil {
@@ -94,7 +146,20 @@ func LoadProjectFromFile(path string) (*models.Project, error) {
 	return &project, nil
 }
 
-func validateLoadedProject(p *models.Project) error {
+// DecodeProject reads a project file in either format: JSON when it starts
+// with an object, YAML otherwise.
+func DecodeProject(data []byte) (*models.Project, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return DecodeProjectJSON(bytes.NewReader(data))
+	}
+	return DecodeProjectYAML(bytes.NewReader(data))
+}
+
+// validateContent checks the fields of a project and of its entities. It
+// runs on load and on save, so a saved project always loads again. References
+// between entities are not checked: a project with dangling references still
+// loads, so it can be repaired, and they are reported by DanglingReferences.
+func validateContent(p *models.Project) error {
 	if p.ID == "" {
 		return fmt.Errorf("project ID is missing")
 	}
@@ -112,16 +177,17 @@ func validateLoadedProject(p *models.Project) error {
 		if f.Name == "" {
 			return fmt.Errorf("fixture[%d] name is missing", i)
 		}
-		for j, ch := range f.Channels {
-			if ch.Name == "" {
-				return fmt.Errorf("fixture[%d].channel[%d] name is missing", i, j)
-			}
-			if ch.Min < 0 || ch.Max > 255 || ch.Min > ch.Max {
-				return fmt.Errorf("fixture[%d].channel[%d] has invalid range", i, j)
-			}
-			if ch.ChannelAddress < 1 || ch.ChannelAddress > 512 {
-				return fmt.Errorf("fixture[%d].channel[%d] has invalid address", i, j)
-			}
+		if err := ValidateFixtureChannels(f.Channels); err != nil {
+			return fmt.Errorf("fixture[%d].%w", i, err)
+		}
+	}
+
+	for i, pal := range p.Palettes {
+		if pal.ID == "" {
+			return fmt.Errorf("palette[%d] ID is missing", i)
+		}
+		if err := palette.Validate(pal); err != nil {
+			return fmt.Errorf("palette[%d] is invalid: %w", i, err)
 		}
 	}
 
@@ -140,6 +206,11 @@ func validateLoadedProject(p *models.Project) error {
 				return fmt.Errorf("preset[%d].channel[%d] has invalid value", i, j)
 			}
 		}
+		for j, ref := range pr.Palettes {
+			if len(ref.FixtureIDs) == 0 {
+				return fmt.Errorf("preset[%d] palette reference[%d] has no fixtures", i, j)
+			}
+		}
 	}
 
 	for i, s := range p.Shows {
@@ -163,15 +234,181 @@ func validateLoadedProject(p *models.Project) error {
 				return fmt.Errorf("show[%d].step[%d] has negative fade time", i, j)
 			}
 		}
+		if err := ValidateShowTimecodes(s); err != nil {
+			return fmt.Errorf("show[%d] %w", i, err)
+		}
+	}
+
+	for i, sc := range p.Schedules {
+		if sc.ID == "" {
+			return fmt.Errorf("schedule[%d] ID is missing", i)
+		}
+		if err := scheduler.Validate(sc); err != nil {
+			return fmt.Errorf("schedule[%d] is invalid: %w", i, err)
+		}
+	}
+
+	for i, pm := range p.PixelMaps {
+		if pm.ID == "" {
+			return fmt.Errorf("pixel_map[%d] ID is missing", i)
+		}
+		if err := pixelmap.Validate(pm); err != nil {
+			return fmt.Errorf("pixel_map[%d] is invalid: %w", i, err)
+		}
+		if err := pixelmap.ValidateFixtures(pm, p.Fixtures); err != nil {
+			return fmt.Errorf("pixel_map[%d] is invalid: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func ValidateShowTimecodes(s models.Show) error {
+	timed := 0
+	var last time.Duration
+	for j, step := range s.Steps {
+		if step.Timecode == "" {
+			continue
+		}
+		timed++
+		pos, err := timecode.Parse(step.Timecode, s.TimecodeFPS)
+		if err != nil {
+			return fmt.Errorf("step[%d] has invalid timecode: %w", j, err)
+		}
+		if timed > 1 && pos <= last {
+			return fmt.Errorf("step[%d] timecode must be after the previous step", j)
+		}
+		last = pos
+	}
+	if timed > 0 && timed != len(s.Steps) {
+		return fmt.Errorf("must have a timecode on every step or on none")
+	}
+	if s.TimecodeFPS < 0 || s.TimecodeFPS > 60 {
+		return fmt.Errorf("has invalid timecode frame rate %d", s.TimecodeFPS)
 	}
+	return nil
+}
 
+// ValidateFixtureChannels checks the names, value ranges and DMX addresses of
+// the channels of a fixture.
+func ValidateFixtureChannels(channels []models.FixtureChannel) error {
+	for j, ch := range channels {
+		if ch.Name == "" {
+			return fmt.Errorf("channel[%d] name is missing", j)
+		}
+		if ch.Min < 0 || ch.Max > 255 || ch.Min > ch.Max {
+			return fmt.Errorf("channel[%d] %q has invalid range (must be 0-255, min <= max)", j, ch.Name)
+		}
+		if ch.ChannelAddress < 1 || ch.ChannelAddress > 512 {
+			return fmt.Errorf("channel[%d] %q has invalid address (must be between 1 and 512)", j, ch.Name)
+		}
+		if ch.FineChannelAddress != 0 && (ch.FineChannelAddress < 1 || ch.FineChannelAddress > 512 || ch.FineChannelAddress == ch.ChannelAddress) {
+			return fmt.Errorf("channel[%d] %q has invalid fine address (must be between 1 and 512 and differ from the channel address)", j, ch.Name)
+		}
+	}
 	return nil
 }
 
+// ExportProjectJSON writes project as indented JSON, replacing path
+// atomically.
 func ExportProjectJSON(project *models.Project, path string) error {
-	return fmt.Errorf("JSON export not yet implemented")
+	if project == nil {
+		return fmt.Errorf("cannot export nil project")
+	}
+
+	project.SchemaVersion = CurrentSchemaVersion
+	data, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode project to JSON: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %q: %w", dir, err)
+	}
+
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write file %q: %w", tempFile, err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to export project: %w", err)
+	}
+	return nil
 }
 
 func ImportProjectJSON(path string) (*models.Project, error) {
-	return nil, fmt.Errorf("JSON import not yet implemented")
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("project file not found: %q", path)
+		}
+		return nil, fmt.Errorf("failed to stat file %q: %w", path, err)
+	}
+	if info.Size() > maxProjectFileSize {
+		return nil, fmt.Errorf("project file too large: %d bytes (max %d)", info.Size(), maxProjectFileSize)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %q: %w", path, err)
+	}
+	defer f.Close()
+
+	project, err := DecodeProjectJSON(f)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", path, err)
+	}
+	return project, nil
+}
+
+// DecodeProjectJSON reads a project exported by ExportProjectJSON. Older
+// schema versions are upgraded, unknown fields are rejected and the project is
+// validated like a loaded YAML file.
+func DecodeProjectJSON(r io.Reader) (*models.Project, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxProjectFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project: %w", err)
+	}
+	if len(data) > maxProjectFileSize {
+		return nil, fmt.Errorf("project file too large (max %d bytes)", maxProjectFileSize)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("project file is empty")
+	}
+	upgraded, _, err := upgradeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var project models.Project
+	decoder := json.NewDecoder(bytes.NewReader(upgraded))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&project); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("project file is empty")
+		}
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after project")
+	}
+
+	if err := validateContent(&project); err != nil {
+		return nil, fmt.Errorf("invalid project: %w", err)
+	}
+
+	if project.Fixtures == nil {
+		project.Fixtures = []models.Fixture{}
+	}
+	if project.Presets == nil {
+		project.Presets = []models.Preset{}
+	}
+	if project.Shows == nil {
+		project.Shows = []models.Show{}
+	}
+
+	return &project, nil
 }
@@ -0,0 +1,63 @@
+package timecode
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock follows an external timecode source. Between updates the position is
+// extrapolated from the wall clock; once no update has arrived for longer than
+// the timeout the clock is considered stopped.
+type Clock struct {
+	mu       sync.Mutex
+	position time.Duration
+	updated  time.Time
+	running  bool
+	fps      int
+	timeout  time.Duration
+	now      func() time.Time
+}
+
+func NewClock(timeout time.Duration) *Clock {
+	return &Clock{timeout: timeout, fps: DefaultFPS, now: time.Now}
+}
+
+func (c *Clock) Set(position time.Duration, fps int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.position = position
+	c.updated = c.now()
+	c.running = true
+	if fps > 0 {
+		c.fps = fps
+	}
+}
+
+func (c *Clock) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		c.position += c.now().Sub(c.updated)
+	}
+	c.running = false
+}
+
+func (c *Clock) Position() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return c.position, false
+	}
+	elapsed := c.now().Sub(c.updated)
+	if c.timeout > 0 && elapsed > c.timeout {
+		c.running = false
+		return c.position, false
+	}
+	return c.position + elapsed, true
+}
+
+func (c *Clock) FPS() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fps
+}
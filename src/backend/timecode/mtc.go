@@ -0,0 +1,115 @@
+package timecode
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var mtcRates = [4]int{24, 25, 30, 30}
+
+// MTCDecoder turns a raw MIDI byte stream into timecode positions. It understands
+// quarter-frame messages (0xF1) and full-frame SysEx messages, and ignores
+// everything else, including interleaved real-time bytes.
+type MTCDecoder struct {
+	pieces   [8]byte
+	seen     byte
+	inSysEx  bool
+	sysex    []byte
+	expectQF bool
+}
+
+func (d *MTCDecoder) Feed(b byte) (time.Duration, int, bool) {
+	if d.expectQF && b < 0x80 {
+		d.expectQF = false
+		return d.quarterFrame(b)
+	}
+	if b >= 0xF8 {
+		return 0, 0, false
+	}
+	d.expectQF = false
+	switch {
+	case b == 0xF1:
+		d.expectQF = true
+	case b == 0xF0:
+		d.inSysEx = true
+		d.sysex = d.sysex[:0]
+	case b == 0xF7:
+		if d.inSysEx {
+			d.inSysEx = false
+			return d.fullFrame()
+		}
+	case b >= 0x80:
+		d.inSysEx = false
+	case d.inSysEx:
+		if len(d.sysex) < 16 {
+			d.sysex = append(d.sysex, b)
+		}
+	}
+	return 0, 0, false
+}
+
+func (d *MTCDecoder) quarterFrame(data byte) (time.Duration, int, bool) {
+	part := int(data >> 4)
+	if part > 7 {
+		return 0, 0, false
+	}
+	if part == 0 {
+		d.seen = 0
+	}
+	d.pieces[part] = data & 0x0F
+	d.seen |= 1 << part
+	if part != 7 || d.seen != 0xFF {
+		return 0, 0, false
+	}
+	frames := int(d.pieces[0]) | int(d.pieces[1]&0x01)<<4
+	seconds := int(d.pieces[2]) | int(d.pieces[3]&0x03)<<4
+	minutes := int(d.pieces[4]) | int(d.pieces[5]&0x03)<<4
+	hours := int(d.pieces[6]) | int(d.pieces[7]&0x01)<<4
+	fps := mtcRates[(d.pieces[7]>>1)&0x03]
+	// A full quarter-frame cycle spans two frames, so the assembled value is
+	// already two frames behind the sender.
+	pos := FromFields(hours, minutes, seconds, frames+2, fps)
+	return pos, fps, true
+}
+
+func (d *MTCDecoder) fullFrame() (time.Duration, int, bool) {
+	// F0 7F <device> 01 01 hr mn sc fr F7
+	s := d.sysex
+	if len(s) != 8 || s[0] != 0x7F || s[2] != 0x01 || s[3] != 0x01 {
+		return 0, 0, false
+	}
+	fps := mtcRates[(s[4]>>5)&0x03]
+	pos := FromFields(int(s[4]&0x1F), int(s[5]), int(s[6]), int(s[7]), fps)
+	return pos, fps, true
+}
+
+// ReadMTC decodes MIDI Timecode from r until it fails, feeding every complete
+// position into clk.
+func ReadMTC(r io.Reader, clk *Clock) error {
+	var dec MTCDecoder
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		for _, b := range buf[:n] {
+			if pos, fps, ok := dec.Feed(b); ok {
+				clk.Set(pos, fps)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ListenMTC reads MIDI Timecode from a raw MIDI device such as an ALSA
+// virtual port (/dev/snd/midiC1D0).
+func ListenMTC(device string, clk *Clock) error {
+	f, err := os.Open(device)
+	if err != nil {
+		return fmt.Errorf("failed to open MIDI device %q: %w", device, err)
+	}
+	defer f.Close()
+	return ReadMTC(f, clk)
+}
@@ -0,0 +1,57 @@
+package timecode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultFPS = 25
+
+func Parse(tc string, fps int) (time.Duration, error) {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	parts := strings.FieldsFunc(tc, func(r rune) bool { return r == ':' || r == ';' || r == '.' })
+	if len(parts) != 4 {
+		return 0, fmt.Errorf("timecode must be HH:MM:SS:FF, got %q", tc)
+	}
+	var v [4]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid timecode field %q in %q", p, tc)
+		}
+		v[i] = n
+	}
+	if v[1] > 59 || v[2] > 59 || v[3] >= fps {
+		return 0, fmt.Errorf("timecode %q out of range at %d fps", tc, fps)
+	}
+	return FromFields(v[0], v[1], v[2], v[3], fps), nil
+}
+
+func FromFields(hours, minutes, seconds, frames, fps int) time.Duration {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second
+	return d + time.Duration(frames)*time.Second/time.Duration(fps)
+}
+
+func Format(d time.Duration, fps int) string {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	if d < 0 {
+		d = 0
+	}
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+	d -= s * time.Second
+	f := d * time.Duration(fps) / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", h, m, s, f)
+}
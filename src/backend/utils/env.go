@@ -1,19 +1,59 @@
 package utils
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 type Config struct {
-	ServerPort   string
-	DMXPort      string
-	DataFilePath string
-	EnableDMX    bool
+	ServerPort    string
+	DMXPort       string
+	DataFilePath  string
+	ProjectsDir   string
+	ProjectFormat string
+	EnableDMX     bool
+	MTCDevice     string
+	OSCAddress    string
+	MediaDir      string
+	MIDIDevice    string
+	CrossfadeCC   int
+
+	BackupInterval   time.Duration
+	BackupEverySaves int
+	BackupKeep       int
+	BackupMaxAge     time.Duration
+
+	// ProjectWatchInterval is how often the project file is checked for
+	// changes made outside the server. Zero disables watching.
+	ProjectWatchInterval time.Duration
+
+	AuthFile   string
+	AdminToken string
 }
 
 func LoadConfig() *Config {
 	return &Config{
-		ServerPort:   GetEnv("SERVER_PORT", ":3000"),
-		DataFilePath: GetEnv("DATA_FILE", ".data/project.yaml"),
-		EnableDMX:    GetEnvBool("ENABLE_DMX", true),
+		ServerPort:    GetEnv("SERVER_PORT", ":3000"),
+		DataFilePath:  GetEnv("DATA_FILE", ".data/project.yaml"),
+		ProjectsDir:   GetEnv("PROJECTS_DIR", ".data/projects"),
+		ProjectFormat: GetEnv("PROJECT_FORMAT", "yaml"),
+		EnableDMX:     GetEnvBool("ENABLE_DMX", true),
+		MTCDevice:     GetEnv("MTC_DEVICE", ""),
+		OSCAddress:    GetEnv("OSC_ADDRESS", ""),
+		MediaDir:      GetEnv("MEDIA_DIR", ".data/media"),
+		MIDIDevice:    GetEnv("MIDI_DEVICE", ""),
+		CrossfadeCC:   GetEnvInt("CROSSFADE_CC", 1),
+
+		BackupInterval:   GetEnvDuration("BACKUP_INTERVAL", 5*time.Minute),
+		BackupEverySaves: GetEnvInt("BACKUP_EVERY_SAVES", 0),
+		BackupKeep:       GetEnvInt("BACKUP_KEEP", 10),
+		BackupMaxAge:     GetEnvDuration("BACKUP_MAX_AGE", 0),
+
+		ProjectWatchInterval: GetEnvDuration("PROJECT_WATCH_INTERVAL", time.Second),
+
+		AuthFile:   GetEnv("AUTH_FILE", ".data/tokens.yaml"),
+		AdminToken: GetEnv("ADMIN_TOKEN", ""),
 	}
 }
 
@@ -31,3 +71,19 @@ func GetEnvBool(key string, defaultValue bool) bool {
 	}
 	return value == "true" || value == "1" || value == "yes"
 }
+
+func GetEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,295 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"elano.fr/src/backend/dmx"
+	"elano.fr/src/backend/models"
+	"github.com/gofiber/contrib/websocket"
+)
+
+const (
+	ApplyReplace  = "replace"
+	ApplyMerge    = "merge"
+	ApplyRelative = "relative"
+)
+
+type actionError struct {
+	errType string
+	message string
+	details string
+}
+
+func (e *actionError) Error() string {
+	if e.details != "" {
+		return e.message + ": " + e.details
+	}
+	return e.message
+}
+
+func newActionError(errType, message, details string) error {
+	return &actionError{errType: errType, message: message, details: details}
+}
+
+func sendActionError(c *websocket.Conn, err error) {
+	if ae, ok := err.(*actionError); ok {
+		sendError(c, ae.errType, ae.message, ae.details)
+		return
+	}
+	sendError(c, "internal_error", "Internal server error", err.Error())
+}
+
+// RunAction runs a scheduled action. It ignores the control lock: the
+// schedule is part of the project and runs whoever holds the lock.
+func RunAction(action models.ScheduleAction) error {
+	switch action.Type {
+	case models.ActionRunShow:
+		return RunShow(action.ShowID, action.Loop)
+	case models.ActionApplyPreset:
+		return ApplyPreset(action.PresetID)
+	case models.ActionBlackout:
+		return Blackout()
+	default:
+		return fmt.Errorf("unknown action type %q", action.Type)
+	}
+}
+
+func ApplyPreset(presetID string) error {
+	p := findPreset(presetID)
+	if p == nil {
+		return newActionError("preset_not_found", "Preset not found", presetID)
+	}
+	return applyPreset(presetPayload(*p), p.ID, ApplyOptions{})
+}
+
+func RunShow(showID string, loop bool) error {
+	show, showModel, err := buildShowPayload(showID, loop)
+	if err != nil {
+		return err
+	}
+	return startShow(show, showID, showModel)
+}
+
+func RunTransientShow(showID string, steps []map[int]byte, durationMs, fadeMs int, loop bool) error {
+	show := ShowPayload{Loop: loop, Steps: make([]ShowStep, len(steps))}
+	for i, channels := range steps {
+		preset := make(PresetPayload, len(channels))
+		for addr, v := range channels {
+			preset[strconv.Itoa(addr)] = int(v)
+		}
+		show.Steps[i] = ShowStep{Preset: preset, Duration: durationMs, FadeMs: fadeMs}
+	}
+	return startShow(show, showID, nil)
+}
+
+func Blackout() error {
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	if ctrl == nil {
+		return newActionError("dmx_error", "DMX controller not initialized", "")
+	}
+	if err := ctrl.Blackout(); err != nil {
+		return newActionError("dmx_error", "Failed to blackout", err.Error())
+	}
+	presetMu.Lock()
+	activePresetID = ""
+	presetMu.Unlock()
+	showMu.Lock()
+	if currentShow != nil {
+		currentShow.cancel()
+		currentShow = nil
+	}
+	showMu.Unlock()
+	stopAllEffects()
+	stopAllPixelMaps()
+	clearCrossfade()
+	broadcast <- Message{Type: "blackout", Payload: []byte("{}")}
+	return nil
+}
+
+// applyPreset puts preset on stage. Replace blacks out every other channel,
+// merge only changes the listed channels and relative adds the listed values
+// (-255 to 255) to the current ones. With a fade time the change is faded
+// using the given curve instead of being applied at once.
+func applyPreset(preset PresetPayload, presetID string, opts ApplyOptions) error {
+	if opts.Mode == "" {
+		opts.Mode = ApplyReplace
+	}
+	switch opts.Mode {
+	case ApplyReplace, ApplyMerge, ApplyRelative:
+	default:
+		return newActionError("invalid_payload", "Unknown apply mode", opts.Mode)
+	}
+	if opts.FadeMs < 0 {
+		return newActionError("invalid_payload", "Fade time cannot be negative", strconv.Itoa(opts.FadeMs))
+	}
+	curve, err := dmx.ParseFadeMode(opts.Curve)
+	if err != nil {
+		return newActionError("invalid_payload", "Invalid fade curve", err.Error())
+	}
+	minValue := 0
+	if opts.Mode == ApplyRelative {
+		minValue = -255
+	}
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	if ctrl == nil {
+		return newActionError("dmx_error", "DMX controller not initialized", "")
+	}
+	values := make(map[int]int)
+	for addrStr, val := range preset {
+		addr, err := strconv.Atoi(addrStr)
+		if err != nil || addr < 1 || addr > 512 || val < minValue || val > 255 {
+			return newActionError("invalid_payload", "Invalid channel data", addrStr)
+		}
+		values[addr] = val
+	}
+
+	channels := make(map[int]byte, len(values))
+	switch opts.Mode {
+	case ApplyReplace:
+		if opts.FadeMs > 0 {
+			// Fade every other channel out rather than cutting to black.
+			for addr := 1; addr <= 512; addr++ {
+				channels[addr] = 0
+			}
+		}
+		for addr, v := range values {
+			channels[addr] = byte(v)
+		}
+	case ApplyMerge:
+		for addr, v := range values {
+			channels[addr] = byte(v)
+		}
+	case ApplyRelative:
+		current, err := ctrl.GetAllChannels()
+		if err != nil {
+			return newActionError("dmx_error", "Failed to read channels", err.Error())
+		}
+		for addr, offset := range values {
+			channels[addr] = byte(max(0, min(255, int(current[addr-1])+offset)))
+		}
+	}
+
+	if opts.FadeMs > 0 {
+		if err := ctrl.FadeChannels(channels, time.Duration(opts.FadeMs)*time.Millisecond, curve); err != nil {
+			return newActionError("dmx_error", "Failed to fade channels", err.Error())
+		}
+	} else {
+		if opts.Mode == ApplyReplace {
+			if err := ctrl.Blackout(); err != nil {
+				return newActionError("dmx_error", "Failed to blackout", err.Error())
+			}
+		}
+		if err := ctrl.SetChannels(channels); err != nil {
+			return newActionError("dmx_error", "Failed to set channels", err.Error())
+		}
+	}
+
+	presetMu.Lock()
+	if opts.Mode == ApplyRelative {
+		activePresetID = ""
+	} else {
+		activePresetID = presetID
+	}
+	presetMu.Unlock()
+	if opts.Mode == ApplyReplace {
+		showMu.Lock()
+		if currentShow != nil {
+			currentShow.cancel()
+			currentShow = nil
+		}
+		showMu.Unlock()
+	}
+	broadcast <- Message{Type: "preset_applied", Payload: mustMarshal(map[string]interface{}{"preset_id": presetID, "channels": preset, "mode": opts.Mode, "fade_ms": opts.FadeMs})}
+	return nil
+}
+
+func buildShowPayload(showID string, loop bool) (ShowPayload, *models.Show, error) {
+	var show ShowPayload
+	if projectStore == nil {
+		return show, nil, newActionError("show_not_found", "Show not found", showID)
+	}
+	project := projectStore.Get()
+	if project == nil {
+		return show, nil, newActionError("show_not_found", "Show not found", showID)
+	}
+	for _, s := range project.Shows {
+		if s.ID != showID {
+			continue
+		}
+		showModel := s
+		show.Loop = loop
+		show.TimecodeFPS = s.TimecodeFPS
+		show.Steps = make([]ShowStep, len(s.Steps))
+		for i, step := range s.Steps {
+			found := false
+			for _, p := range project.Presets {
+				if p.ID == step.PresetID {
+					show.Steps[i] = ShowStep{PresetID: p.ID, Preset: presetPayload(p), Duration: step.Duration, FadeMs: step.FadeMS, Timecode: step.Timecode}
+					found = true
+					break
+				}
+			}
+			if !found {
+				return show, nil, newActionError("preset_not_found", fmt.Sprintf("Show step %d references unknown preset", i+1), step.PresetID)
+			}
+		}
+		return show, &showModel, nil
+	}
+	return show, nil, newActionError("show_not_found", "Show not found", showID)
+}
+
+func startShow(show ShowPayload, showID string, showModel *models.Show) error {
+	if len(show.Steps) == 0 {
+		return newActionError("invalid_show", "Show must have at least one step", "")
+	}
+	var anchors []time.Duration
+	useTimecode := isTimecodeShow(show)
+	if useTimecode {
+		var err error
+		if anchors, err = timecodeAnchors(show); err != nil {
+			return newActionError("invalid_show", "Invalid show timecode", err.Error())
+		}
+	}
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	if ctrl == nil {
+		return newActionError("dmx_error", "DMX controller not initialized", "")
+	}
+	showMu.Lock()
+	if currentShow != nil {
+		currentShow.cancel()
+		showMu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		showMu.Lock()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	currentShow = &ShowController{cancel: cancel, id: showID, currentStep: 0, showData: showModel, loop: show.Loop}
+	showMu.Unlock()
+	presetMu.Lock()
+	activePresetID = ""
+	presetMu.Unlock()
+	broadcast <- Message{Type: "show_started", Payload: mustMarshal(map[string]interface{}{"show_id": showID, "steps": len(show.Steps), "loop": show.Loop, "timecode": useTimecode})}
+	if useTimecode {
+		go runTimecodeShow(ctx, ctrl, show, anchors, showID)
+	} else {
+		go runShowSequence(ctx, ctrl, getShowClock(), show, showID)
+	}
+	return nil
+}
+
+func presetPayload(p models.Preset) PresetPayload {
+	channels := presetChannels(p)
+	preset := make(PresetPayload, len(channels))
+	for addr, v := range channels {
+		preset[strconv.Itoa(addr)] = int(v)
+	}
+	return preset
+}
@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"elano.fr/src/backend/auth"
+	"github.com/gofiber/contrib/websocket"
+)
+
+// messageRoles are the messages that need more than auth.Operator, which is
+// enough to drive the output.
+var messageRoles = map[string]auth.Role{
+	"get_status":         auth.Viewer,
+	"get_dmx_state":      auth.Viewer,
+	"get_project_config": auth.Viewer,
+	"get_effects":        auth.Viewer,
+	"start_monitoring":   auth.Viewer,
+	"stop_monitoring":    auth.Viewer,
+	"record_preset":      auth.Programmer,
+}
+
+func messageRole(msgType string) auth.Role {
+	if role, ok := messageRoles[msgType]; ok {
+		return role
+	}
+	return auth.Operator
+}
+
+var tokens *auth.Tokens
+
+// SetTokens sets the API tokens the identity of the clients is checked
+// against for each message.
+func SetTokens(t *auth.Tokens) {
+	tokens = t
+}
+
+// clientIdentity returns who opened a connection, as set by the
+// authentication middleware before the upgrade, with the current role of
+// its token. A client whose token has been revoked since has no role.
+func clientIdentity(c *websocket.Conn) *auth.Identity {
+	id, ok := c.Locals("identity").(*auth.Identity)
+	if !ok {
+		return &auth.Identity{Role: auth.None}
+	}
+	if tokens == nil {
+		return id
+	}
+	if current, ok := tokens.Refresh(id); ok {
+		return current
+	}
+	return &auth.Identity{TokenID: id.TokenID, Name: id.Name, Role: auth.None}
+}
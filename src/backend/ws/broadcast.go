@@ -2,32 +2,71 @@ package ws
 
 import (
 	"log"
+	"sync"
 
 	"github.com/gofiber/contrib/websocket"
 )
 
+// clientQueueSize is how many broadcast messages may wait for a client. A
+// client falling further behind is disconnected, and gets the current state
+// again when it reconnects.
+const clientQueueSize = 256
+
+// client is a connected WebSocket client. mu serialises the writes to the
+// connection, and broadcast messages go through queue so that the client
+// receives them in the order they were sent.
+type client struct {
+	mu    sync.Mutex
+	queue chan Message
+	done  chan struct{}
+}
+
+func addClient(c *websocket.Conn) {
+	cl := &client{queue: make(chan Message, clientQueueSize), done: make(chan struct{})}
+	clients.Store(c, cl)
+	go cl.send(c)
+}
+
+func removeClient(c *websocket.Conn) {
+	if v, ok := clients.LoadAndDelete(c); ok {
+		close(v.(*client).done)
+	}
+}
+
+func (cl *client) send(c *websocket.Conn) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in broadcast: %v", r)
+			removeClient(c)
+		}
+	}()
+	for {
+		select {
+		case <-cl.done:
+			return
+		case msg := <-cl.queue:
+			if err := writeJSON(c, msg); err != nil {
+				log.Printf("Error broadcasting to client: %v", err)
+				removeClient(c)
+				return
+			}
+		}
+	}
+}
+
 func handleBroadcast() {
 	for msg := range broadcast {
-		var conns []*websocket.Conn
-		clients.Range(func(key, _ interface{}) bool {
-			if c, ok := key.(*websocket.Conn); ok {
-				conns = append(conns, c)
+		clients.Range(func(key, value interface{}) bool {
+			c, cl := key.(*websocket.Conn), value.(*client)
+			select {
+			case cl.queue <- msg:
+			case <-cl.done:
+			default:
+				log.Printf("WebSocket client %v too slow, disconnecting", c.Locals("id"))
+				removeClient(c)
+				c.Close()
 			}
 			return true
 		})
-		for _, c := range conns {
-			go func(c *websocket.Conn) {
-				defer func() {
-					if r := recover(); r != nil {
-						log.Printf("Recovered from panic in broadcast: %v", r)
-						clients.Delete(c)
-					}
-				}()
-				if err := writeJSON(c, msg); err != nil {
-					log.Printf("Error broadcasting to client: %v", err)
-					clients.Delete(c)
-				}
-			}(c)
-		}
 	}
 }
@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Clock abstracts time for show playback so timing can be driven by a fake
+// clock in tests.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+const minLoopInterval = 100 * time.Millisecond
+
+var (
+	showClock   Clock = realClock{}
+	showClockMu sync.RWMutex
+)
+
+// SetShowClock sets the clock used by shows started afterwards; a running show
+// keeps the clock it started with.
+func SetShowClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	showClockMu.Lock()
+	showClock = c
+	showClockMu.Unlock()
+}
+
+func getShowClock() Clock {
+	showClockMu.RLock()
+	defer showClockMu.RUnlock()
+	return showClock
+}
+
+func (t *ShowTiming) record(lateness time.Duration) {
+	ms := float64(lateness) / float64(time.Millisecond)
+	t.MeanErrorMs = (t.MeanErrorMs*float64(t.Steps) + ms) / float64(t.Steps+1)
+	t.Steps++
+	t.LastErrorMs = ms
+	if math.Abs(ms) > math.Abs(t.MaxErrorMs) {
+		t.MaxErrorMs = ms
+	}
+}
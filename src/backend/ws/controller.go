@@ -10,6 +10,37 @@ import (
 
 func SetProjectStore(store storage.ProjectStore) {
 	projectStore = store
+	store.OnChange(broadcastProjectChanges)
+}
+
+// broadcastProjectChanges sends one project_changed message per changed
+// entity, carrying the entity as saved (or nothing once it is deleted).
+func broadcastProjectChanges(changes []storage.Change) {
+	for _, ch := range changes {
+		broadcast <- Message{Type: "project_changed", Payload: mustMarshal(map[string]interface{}{
+			"entity": ch.Entity,
+			"id":     ch.ID,
+			"op":     ch.Op,
+			"data":   ch.After,
+		})}
+	}
+}
+
+// ProjectReloaded tells clients that the project files were changed outside
+// the server. The changed entities themselves are broadcast as
+// project_changed by the store.
+func ProjectReloaded(changes []storage.Change, err error) {
+	if err != nil {
+		log.Printf("Warning: project reload failed: %v", err)
+		broadcast <- Message{Type: "project_reload_failed", Payload: mustMarshal(map[string]interface{}{"error": err.Error()})}
+		return
+	}
+	config := projectConfig()
+	if config == nil {
+		return
+	}
+	broadcast <- Message{Type: "project_reloaded", Payload: mustMarshal(map[string]interface{}{"project_id": config["project_id"], "changes": len(changes)})}
+	broadcast <- Message{Type: "project_config", Payload: mustMarshal(config)}
 }
 
 func InitializeDMXController(portName string) error {
@@ -25,6 +56,9 @@ func InitializeDMXController(portName string) error {
 		return err
 	}
 	dmxCtrl = ctrl
+	restoreSubmasters(ctrl)
+	ctrl.SetFrameProcessor("pixelmap", pixelEngine.Apply)
+	ctrl.SetFrameProcessor("effects", effectEngine.Apply)
 	if getClientCount() > 0 {
 		go func() {
 			time.Sleep(100 * time.Millisecond)
@@ -45,3 +79,45 @@ func CloseDMXController() error {
 	}
 	return nil
 }
+
+// ProjectSwitched stops everything still playing from the previous project
+// and sends the newly active project to every client.
+func ProjectSwitched() {
+	showMu.Lock()
+	if currentShow != nil {
+		currentShow.cancel()
+		currentShow = nil
+	}
+	showMu.Unlock()
+	presetMu.Lock()
+	activePresetID = ""
+	presetMu.Unlock()
+	stopAllEffects()
+	stopAllPixelMaps()
+	clearCrossfade()
+
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	playbackMu.Lock()
+	for id := range submasters {
+		if ctrl != nil {
+			ctrl.RemoveSubmaster(id)
+		}
+		delete(submasters, id)
+	}
+	playbackLevels = make(map[string]float64)
+	playbackMu.Unlock()
+	if ctrl != nil {
+		if err := ctrl.Blackout(); err != nil {
+			log.Printf("Error during blackout: %v", err)
+		}
+	}
+
+	config := projectConfig()
+	if config == nil {
+		return
+	}
+	broadcast <- Message{Type: "project_switched", Payload: mustMarshal(map[string]interface{}{"project_id": config["project_id"], "project_name": config["project_name"]})}
+	broadcast <- Message{Type: "project_config", Payload: mustMarshal(config)}
+}
@@ -0,0 +1,174 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/crossfade"
+	"elano.fr/src/backend/midi"
+	"github.com/gofiber/contrib/websocket"
+)
+
+type crossfadeState struct {
+	presetA  string
+	presetB  string
+	a, b     map[int]byte
+	rules    crossfade.Rules
+	position float64
+}
+
+var (
+	xfade   *crossfadeState
+	xfadeMu sync.Mutex
+)
+
+func handleLoadCrossfade(c *websocket.Conn, payload json.RawMessage) {
+	var p CrossfadePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid crossfade payload", err.Error())
+		return
+	}
+	if err := LoadCrossfade(p.PresetA, p.PresetB, p.Position); err != nil {
+		sendActionError(c, err)
+	}
+}
+
+func handleSetCrossfade(c *websocket.Conn, payload json.RawMessage) {
+	var p CrossfadePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid crossfade payload", err.Error())
+		return
+	}
+	if p.Position == nil {
+		sendError(c, "invalid_payload", "Crossfade position is required", "")
+		return
+	}
+	if err := SetCrossfade(*p.Position); err != nil {
+		sendActionError(c, err)
+	}
+}
+
+func clearCrossfade() {
+	xfadeMu.Lock()
+	loaded := xfade != nil
+	xfade = nil
+	xfadeMu.Unlock()
+	if loaded {
+		broadcast <- Message{Type: "crossfade_cleared", Payload: []byte("{}")}
+	}
+}
+
+// LoadCrossfade arms a crossfade between two presets and outputs the mix at
+// position, or at 0 (all A) when position is nil. It takes over from any
+// running show.
+func LoadCrossfade(presetA, presetB string, position *float64) error {
+	a := findPreset(presetA)
+	if a == nil {
+		return newActionError("preset_not_found", "Preset not found", presetA)
+	}
+	b := findPreset(presetB)
+	if b == nil {
+		return newActionError("preset_not_found", "Preset not found", presetB)
+	}
+	pos := 0.0
+	if position != nil {
+		pos = *position
+	}
+	if pos < 0 || pos > 1 {
+		return newActionError("invalid_payload", "Crossfade position must be 0.0-1.0", fmt.Sprintf("%g", pos))
+	}
+	state := &crossfadeState{presetA: a.ID, presetB: b.ID, a: presetChannels(*a), b: presetChannels(*b), position: pos}
+	if project := projectStore.Get(); project != nil {
+		state.rules = crossfade.RulesFromFixtures(project.Fixtures)
+	}
+
+	showMu.Lock()
+	if currentShow != nil {
+		currentShow.cancel()
+		currentShow = nil
+	}
+	showMu.Unlock()
+	presetMu.Lock()
+	activePresetID = ""
+	presetMu.Unlock()
+
+	xfadeMu.Lock()
+	xfade = state
+	err := outputCrossfade(state)
+	xfadeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	broadcast <- Message{Type: "crossfade_loaded", Payload: mustMarshal(crossfadeStatus())}
+	return nil
+}
+
+func SetCrossfade(position float64) error {
+	if position < 0 || position > 1 {
+		return newActionError("invalid_payload", "Crossfade position must be 0.0-1.0", fmt.Sprintf("%g", position))
+	}
+	xfadeMu.Lock()
+	if xfade == nil {
+		xfadeMu.Unlock()
+		return newActionError("crossfade_not_loaded", "No crossfade loaded", "")
+	}
+	xfade.position = position
+	err := outputCrossfade(xfade)
+	xfadeMu.Unlock()
+	if err != nil {
+		return err
+	}
+	broadcast <- Message{Type: "crossfade_position", Payload: mustMarshal(map[string]interface{}{"position": position})}
+	return nil
+}
+
+func outputCrossfade(state *crossfadeState) error {
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	if ctrl == nil {
+		return newActionError("dmx_error", "DMX controller not initialized", "")
+	}
+	if err := ctrl.SetChannels(crossfade.Mix(state.a, state.b, state.position, state.rules)); err != nil {
+		return newActionError("dmx_error", "Failed to set channels", err.Error())
+	}
+	return nil
+}
+
+func crossfadeStatus() *CrossfadeState {
+	xfadeMu.Lock()
+	defer xfadeMu.Unlock()
+	if xfade == nil {
+		return nil
+	}
+	return &CrossfadeState{PresetA: xfade.presetA, PresetB: xfade.presetB, Position: xfade.position}
+}
+
+// StartMIDIListener drives the crossfade position from a MIDI control change
+// number on any channel.
+func StartMIDIListener(device string, crossfadeCC int) {
+	go func() {
+		for {
+			log.Printf("Listening for MIDI control changes on %s", device)
+			err := midi.ListenCC(device, func(cc midi.ControlChange) {
+				if cc.Controller != crossfadeCC {
+					return
+				}
+				if err := ControlLocked(); err != nil {
+					log.Printf("MIDI crossfade ignored: %v", err)
+					return
+				}
+				if err := SetCrossfade(float64(cc.Value) / 127); err != nil && ActionErrorType(err) != "crossfade_not_loaded" {
+					log.Printf("MIDI crossfade: %v", err)
+				}
+			})
+			if err != nil {
+				log.Printf("MIDI input stopped: %v", err)
+			}
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
@@ -0,0 +1,115 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"elano.fr/src/backend/effects"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+)
+
+var effectEngine = effects.NewEngine()
+
+func handleStartEffect(c *websocket.Conn, payload json.RawMessage) {
+	var p EffectPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid effect payload", err.Error())
+		return
+	}
+	targets, err := resolveEffectTargets(p)
+	if err != nil {
+		sendActionError(c, err)
+		return
+	}
+	if p.ID == "" {
+		p.ID = uuid.NewString()
+	}
+	ef := effects.Effect{
+		ID:       p.ID,
+		Waveform: effects.Waveform(p.Waveform),
+		Speed:    p.Speed,
+		Size:     p.Size,
+		Offset:   p.Offset,
+		Phase:    p.Phase,
+		Targets:  targets,
+	}
+	if err := effectEngine.Start(ef, time.Now()); err != nil {
+		sendError(c, "invalid_effect", "Invalid effect", err.Error())
+		return
+	}
+	broadcast <- Message{Type: "effect_started", Payload: mustMarshal(ef)}
+}
+
+func handleStopEffect(c *websocket.Conn, payload json.RawMessage) {
+	var p struct {
+		ID  string `json:"id"`
+		All bool   `json:"all"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid effect payload", err.Error())
+		return
+	}
+	if p.All {
+		stopAllEffects()
+		return
+	}
+	if !effectEngine.Stop(p.ID) {
+		sendError(c, "effect_not_found", "Effect not found", p.ID)
+		return
+	}
+	broadcast <- Message{Type: "effect_stopped", Payload: mustMarshal(map[string]interface{}{"id": p.ID})}
+}
+
+func handleGetEffects(c *websocket.Conn) {
+	writeJSON(c, Message{Type: "effects", Payload: mustMarshal(effectEngine.List())})
+}
+
+func stopAllEffects() {
+	for _, id := range effectEngine.StopAll() {
+		broadcast <- Message{Type: "effect_stopped", Payload: mustMarshal(map[string]interface{}{"id": id})}
+	}
+}
+
+func resolveEffectTargets(p EffectPayload) ([][]int, error) {
+	var targets [][]int
+	if len(p.FixtureIDs) > 0 {
+		if projectStore == nil {
+			return nil, newActionError("config_error", "Project store not initialized", "")
+		}
+		project := projectStore.Get()
+		if project == nil {
+			return nil, newActionError("config_error", "No project loaded", "")
+		}
+		for _, id := range p.FixtureIDs {
+			found := false
+			for _, f := range project.Fixtures {
+				if f.ID != id {
+					continue
+				}
+				found = true
+				var group []int
+				for _, ch := range f.Channels {
+					if p.Channel == "" || strings.EqualFold(ch.Name, p.Channel) {
+						group = append(group, ch.ChannelAddress)
+					}
+				}
+				if len(group) > 0 {
+					targets = append(targets, group)
+				}
+				break
+			}
+			if !found {
+				return nil, newActionError("fixture_not_found", "Fixture not found", id)
+			}
+		}
+	}
+	for _, ch := range p.Channels {
+		targets = append(targets, []int{ch})
+	}
+	if len(targets) == 0 {
+		return nil, newActionError("invalid_effect", "Effect has no target channels", p.Channel)
+	}
+	return targets, nil
+}
@@ -9,7 +9,7 @@ import (
 )
 
 var (
-	clients        sync.Map // *websocket.Conn -> *sync.Mutex
+	clients        sync.Map // *websocket.Conn -> *client
 	broadcast      = make(chan Message, 100)
 	dmxCtrl        *dmx.DMXController
 	dmxCtrlMu      sync.RWMutex
@@ -22,4 +22,7 @@ var (
 	monitoring     bool
 	monitorMu      sync.Mutex
 	monitorStop    chan struct{}
+	playbackLevels = make(map[string]float64)
+	submasters     = make(map[string]*SubmasterFader)
+	playbackMu     sync.RWMutex
 )
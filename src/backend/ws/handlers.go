@@ -3,26 +3,25 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
-	"strconv"
-	"sync"
 	"time"
 
 	"elano.fr/src/backend/dmx"
-	"elano.fr/src/backend/models"
 	"github.com/gofiber/contrib/websocket"
 )
 
 func handleWebSocket(c *websocket.Conn) {
-	clients.Store(c, &sync.Mutex{})
+	addClient(c)
 	clientID := c.Locals("id")
-	log.Printf("WebSocket client connected: %v", clientID)
+	log.Printf("WebSocket client connected: %v (%s)", clientID, clientIdentity(c).Name)
 	sendCurrentState(c)
 	if getClientCount() == 1 {
 		startMonitoring()
 	}
 	defer func() {
-		clients.Delete(c)
+		removeClient(c)
+		releaseClientLock(c)
 		log.Printf("WebSocket client disconnected: %v", clientID)
 		c.Close()
 		if getClientCount() == 0 {
@@ -74,9 +73,18 @@ func handleMessage(c *websocket.Conn, msg Message) {
 			sendError(c, "internal_error", "Internal server error", "")
 		}
 	}()
+	if id, required := clientIdentity(c), messageRole(msg.Type); !id.Role.Allows(required) {
+		sendError(c, "forbidden", "Insufficient role", fmt.Sprintf("%s requires the %s role", msg.Type, required))
+		return
+	}
+	if !checkControlLock(c, msg.Type) {
+		return
+	}
 	switch msg.Type {
 	case "apply_preset":
 		handleApplyPreset(c, msg.Payload)
+	case "record_preset":
+		handleRecordPreset(c, msg.Payload)
 	case "run_show":
 		handleRunShow(c, msg.Payload)
 	case "stop_show":
@@ -95,6 +103,36 @@ func handleMessage(c *websocket.Conn, msg Message) {
 		handleStopMonitoring(c)
 	case "get_project_config":
 		handleGetProjectConfig(c)
+	case "set_playback_level":
+		handleSetPlaybackLevel(c, msg.Payload)
+	case "load_submaster":
+		handleLoadSubmaster(c, msg.Payload)
+	case "unload_submaster":
+		handleUnloadSubmaster(c, msg.Payload)
+	case "timecode":
+		handleTimecode(c, msg.Payload)
+	case "timecode_stop":
+		handleTimecodeStop(c)
+	case "start_effect":
+		handleStartEffect(c, msg.Payload)
+	case "stop_effect":
+		handleStopEffect(c, msg.Payload)
+	case "get_effects":
+		handleGetEffects(c)
+	case "load_crossfade":
+		handleLoadCrossfade(c, msg.Payload)
+	case "set_crossfade":
+		handleSetCrossfade(c, msg.Payload)
+	case "clear_crossfade":
+		clearCrossfade()
+	case "start_pixel_map":
+		handleStartPixelMap(c, msg.Payload)
+	case "stop_pixel_map":
+		handleStopPixelMap(c, msg.Payload)
+	case "lock":
+		handleLock(c, msg.Payload)
+	case "unlock":
+		handleUnlock(c, msg.Payload)
 	default:
 		sendError(c, "unknown_type", "Unknown message type", msg.Type)
 	}
@@ -107,69 +145,32 @@ func sendCurrentState(c *websocket.Conn) {
 
 func handleApplyPreset(c *websocket.Conn, payload json.RawMessage) {
 	var idPayload struct {
-		PresetID string `json:"preset_id"`
+		PresetID string        `json:"preset_id"`
+		Channels PresetPayload `json:"channels"`
+		ApplyOptions
 	}
 	var preset PresetPayload
 	var presetID string
 	if err := json.Unmarshal(payload, &idPayload); err == nil && idPayload.PresetID != "" {
-		if projectStore != nil {
-			if project := projectStore.Get(); project != nil {
-				for _, p := range project.Presets {
-					if p.ID == idPayload.PresetID {
-						preset = make(PresetPayload)
-						for _, ch := range p.Channels {
-							preset[strconv.Itoa(ch.DMXAddress)] = int(ch.Value)
-						}
-						presetID = p.ID
-						break
-					}
-				}
-			}
-		}
-		if preset == nil {
+		p := findPreset(idPayload.PresetID)
+		if p == nil {
 			sendError(c, "preset_not_found", "Preset not found", idPayload.PresetID)
 			return
 		}
+		preset = presetPayload(*p)
+		presetID = p.ID
+	} else if err == nil && idPayload.Channels != nil {
+		preset = idPayload.Channels
 	} else {
 		if err := json.Unmarshal(payload, &preset); err != nil {
 			sendError(c, "invalid_payload", "Invalid preset payload", err.Error())
 			return
 		}
+		idPayload.ApplyOptions = ApplyOptions{}
 	}
-	dmxCtrlMu.RLock()
-	ctrl := dmxCtrl
-	dmxCtrlMu.RUnlock()
-	if ctrl == nil {
-		sendError(c, "dmx_error", "DMX controller not initialized", "")
-		return
-	}
-	if err := ctrl.Blackout(); err != nil {
-		sendError(c, "dmx_error", "Failed to blackout", err.Error())
-		return
-	}
-	channels := make(map[int]byte)
-	for addrStr, val := range preset {
-		addr, err := strconv.Atoi(addrStr)
-		if err != nil || addr < 1 || addr > 512 || val < 0 || val > 255 {
-			sendError(c, "invalid_payload", "Invalid channel data", addrStr)
-			return
-		}
-		channels[addr] = byte(val)
-	}
-	if err := ctrl.SetChannels(channels); err != nil {
-		sendError(c, "dmx_error", "Failed to set channels", err.Error())
-		return
-	}
-	presetMu.Lock()
-	activePresetID = presetID
-	presetMu.Unlock()
-	showMu.Lock()
-	if currentShow != nil {
-		currentShow.cancel()
-		currentShow = nil
+	if err := applyPreset(preset, presetID, idPayload.ApplyOptions); err != nil {
+		sendActionError(c, err)
 	}
-	showMu.Unlock()
-	broadcast <- Message{Type: "preset_applied", Payload: mustMarshal(map[string]interface{}{"preset_id": presetID, "channels": preset})}
 }
 
 func handleRunShow(c *websocket.Conn, payload json.RawMessage) {
@@ -177,72 +178,20 @@ func handleRunShow(c *websocket.Conn, payload json.RawMessage) {
 		ShowID string `json:"show_id"`
 		Loop   bool   `json:"loop"`
 	}
-	var show ShowPayload
-	var showID string
-	var showModel *models.Show
 	if err := json.Unmarshal(payload, &idPayload); err == nil && idPayload.ShowID != "" {
-		if projectStore != nil {
-			if project := projectStore.Get(); project != nil {
-				for _, s := range project.Shows {
-					if s.ID == idPayload.ShowID {
-						copy := s
-						showModel = &copy
-						showID = s.ID
-						show.Loop = idPayload.Loop
-						show.Steps = make([]ShowStep, len(s.Steps))
-						for i, step := range s.Steps {
-							for _, p := range project.Presets {
-								if p.ID == step.PresetID {
-									preset := make(PresetPayload)
-									for _, ch := range p.Channels {
-										preset[strconv.Itoa(ch.DMXAddress)] = int(ch.Value)
-									}
-									show.Steps[i] = ShowStep{Preset: preset, Duration: step.Duration, FadeMs: step.FadeMS}
-									break
-								}
-							}
-						}
-						break
-					}
-				}
-			}
+		if err := RunShow(idPayload.ShowID, idPayload.Loop); err != nil {
+			sendActionError(c, err)
 		}
-		if showModel == nil {
-			sendError(c, "show_not_found", "Show not found", idPayload.ShowID)
-			return
-		}
-	} else {
-		if err := json.Unmarshal(payload, &show); err != nil {
-			sendError(c, "invalid_payload", "Invalid show payload", err.Error())
-			return
-		}
-	}
-	if len(show.Steps) == 0 {
-		sendError(c, "invalid_show", "Show must have at least one step", "")
 		return
 	}
-	dmxCtrlMu.RLock()
-	ctrl := dmxCtrl
-	dmxCtrlMu.RUnlock()
-	if ctrl == nil {
-		sendError(c, "dmx_error", "DMX controller not initialized", "")
+	var show ShowPayload
+	if err := json.Unmarshal(payload, &show); err != nil {
+		sendError(c, "invalid_payload", "Invalid show payload", err.Error())
 		return
 	}
-	showMu.Lock()
-	if currentShow != nil {
-		currentShow.cancel()
-		showMu.Unlock()
-		time.Sleep(50 * time.Millisecond)
-		showMu.Lock()
+	if err := startShow(show, "", nil); err != nil {
+		sendActionError(c, err)
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	currentShow = &ShowController{cancel: cancel, id: showID, currentStep: 0, showData: showModel, loop: show.Loop}
-	showMu.Unlock()
-	presetMu.Lock()
-	activePresetID = ""
-	presetMu.Unlock()
-	broadcast <- Message{Type: "show_started", Payload: mustMarshal(map[string]interface{}{"show_id": showID, "steps": len(show.Steps), "loop": show.Loop})}
-	go runShowSequence(ctx, ctrl, show, showID)
 }
 
 func handleStopShow(c *websocket.Conn) {
@@ -283,27 +232,9 @@ func handleUpdateChannel(c *websocket.Conn, payload json.RawMessage) {
 }
 
 func handleBlackout(c *websocket.Conn) {
-	dmxCtrlMu.RLock()
-	ctrl := dmxCtrl
-	dmxCtrlMu.RUnlock()
-	if ctrl == nil {
-		sendError(c, "dmx_error", "DMX controller not initialized", "")
-		return
-	}
-	if err := ctrl.Blackout(); err != nil {
-		sendError(c, "dmx_error", "Failed to blackout", err.Error())
-		return
-	}
-	presetMu.Lock()
-	activePresetID = ""
-	presetMu.Unlock()
-	showMu.Lock()
-	if currentShow != nil {
-		currentShow.cancel()
-		currentShow = nil
+	if err := Blackout(); err != nil {
+		sendActionError(c, err)
 	}
-	showMu.Unlock()
-	broadcast <- Message{Type: "blackout", Payload: json.RawMessage("{}")}
 }
 
 func handleGetDMXState(c *websocket.Conn) {
@@ -332,13 +263,16 @@ func handleGetDMXState(c *websocket.Conn) {
 	var as string
 	var step int
 	var loop bool
+	var timing *ShowTiming
 	if currentShow != nil {
 		as = currentShow.id
 		step = currentShow.currentStep
 		loop = currentShow.loop
+		t := currentShow.timing
+		timing = &t
 	}
 	showMu.Unlock()
-	state := DMXState{Channels: states, ActivePresetID: ap, ActiveShowID: as, ShowStep: step, ShowLoop: loop, Timestamp: time.Now().UnixMilli()}
+	state := DMXState{Channels: states, ActivePresetID: ap, ActiveShowID: as, ShowStep: step, ShowLoop: loop, ShowTiming: timing, Playbacks: currentPlaybacks(), Timestamp: time.Now().UnixMilli()}
 	writeJSON(c, Message{Type: "dmx_state", Payload: mustMarshal(state)})
 }
 
@@ -347,19 +281,38 @@ func handleGetProjectConfig(c *websocket.Conn) {
 		sendError(c, "config_error", "Project store not initialized", "")
 		return
 	}
-	project := projectStore.Get()
-	if project == nil {
+	config := projectConfig()
+	if config == nil {
 		sendError(c, "config_error", "No project loaded", "")
 		return
 	}
-	config := map[string]interface{}{
+	writeJSON(c, Message{Type: "project_config", Payload: mustMarshal(config)})
+}
+
+func projectConfig() map[string]interface{} {
+	project := projectStore.Get()
+	if project == nil {
+		return nil
+	}
+	return map[string]interface{}{
 		"project_id":   project.ID,
 		"project_name": project.Name,
 		"fixtures":     project.Fixtures,
 		"presets":      project.Presets,
 		"shows":        project.Shows,
 	}
-	writeJSON(c, Message{Type: "project_config", Payload: mustMarshal(config)})
+}
+
+// NotifyHistory tells every client that an edit was undone or redone and
+// sends the resulting project so views can refresh.
+func NotifyHistory(action string, entry interface{}) {
+	broadcast <- Message{Type: "history_changed", Payload: mustMarshal(map[string]interface{}{"action": action, "entry": entry})}
+	if projectStore == nil {
+		return
+	}
+	if config := projectConfig(); config != nil {
+		broadcast <- Message{Type: "project_config", Payload: mustMarshal(config)}
+	}
 }
 
 func handleStartMonitoring(c *websocket.Conn) {
@@ -0,0 +1,253 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"elano.fr/src/backend/auth"
+	"github.com/gofiber/contrib/websocket"
+)
+
+const (
+	defaultLockTimeout = 5 * time.Minute
+	maxLockTimeout     = 12 * time.Hour
+)
+
+// ControlLock gives one client exclusive control of the output: while it is
+// held, the other clients can only send messages that need no more than
+// auth.Viewer. It expires when its owner sends nothing for its timeout, and is
+// released when its owner disconnects.
+type ControlLock struct {
+	ClientID   string    `json:"client_id"`
+	Owner      string    `json:"owner"`
+	Role       auth.Role `json:"role"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	TimeoutMs  int64     `json:"timeout_ms"`
+
+	conn    *websocket.Conn
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+var (
+	controlLock   *ControlLock
+	controlLockMu sync.Mutex
+)
+
+// lockStatus returns a copy of the current lock, or nil.
+func lockStatus() *ControlLock {
+	controlLockMu.Lock()
+	defer controlLockMu.Unlock()
+	if controlLock == nil {
+		return nil
+	}
+	l := *controlLock
+	return &l
+}
+
+func clientID(c *websocket.Conn) string {
+	if id, ok := c.Locals("id").(string); ok {
+		return id
+	}
+	return fmt.Sprintf("%p", c)
+}
+
+// liveLock returns the current lock, or nil. A lock whose owner has lost the
+// operator role is released first, and revoked tells the caller to broadcast
+// it once controlLockMu, which must be held, is unlocked.
+func liveLock() (lock *ControlLock, revoked bool) {
+	if controlLock == nil {
+		return nil, false
+	}
+	if !clientIdentity(controlLock.conn).Role.Allows(auth.Operator) {
+		// The token of the owner has been revoked.
+		controlLock.timer.Stop()
+		controlLock = nil
+		return nil, true
+	}
+	return controlLock, false
+}
+
+func lockedDetails(lock *ControlLock) string {
+	return fmt.Sprintf("%s holds the control lock until %s", lock.Owner, lock.ExpiresAt.Format(time.RFC3339))
+}
+
+// checkControlLock rejects messages that drive the output from clients other
+// than the lock owner, and keeps the lock of its owner alive. lock and unlock
+// check the lock themselves.
+func checkControlLock(c *websocket.Conn, msgType string) bool {
+	if msgType == "lock" || msgType == "unlock" {
+		return true
+	}
+	controlLockMu.Lock()
+	lock, revoked := liveLock()
+	if lock == nil {
+		controlLockMu.Unlock()
+		if revoked {
+			broadcastLock("revoked", nil)
+		}
+		return true
+	}
+	if lock.conn == c {
+		lock.ExpiresAt = time.Now().Add(lock.timeout)
+		lock.timer.Reset(lock.timeout)
+		controlLockMu.Unlock()
+		return true
+	}
+	details := lockedDetails(lock)
+	controlLockMu.Unlock()
+	if messageRole(msgType) == auth.Viewer {
+		return true
+	}
+	sendError(c, "locked", "Control is locked by another client", details)
+	return false
+}
+
+// ControlLocked returns a "locked" action error while a WebSocket client holds
+// the control lock. The inputs that drive the output without a WebSocket
+// connection, which can never own the lock, check it first: the REST API,
+// OSC and MIDI. Scheduled actions are exempt, since they are part of the
+// project and must run at their time whoever holds the lock.
+func ControlLocked() error {
+	controlLockMu.Lock()
+	lock, revoked := liveLock()
+	var details string
+	if lock != nil {
+		details = lockedDetails(lock)
+	}
+	controlLockMu.Unlock()
+	if revoked {
+		broadcastLock("revoked", nil)
+	}
+	if lock == nil {
+		return nil
+	}
+	return newActionError("locked", "Control is locked by another client", details)
+}
+
+func broadcastLock(reason string, lock *ControlLock) {
+	broadcast <- Message{Type: "lock_changed", Payload: mustMarshal(map[string]interface{}{
+		"reason": reason,
+		"lock":   lock,
+	})}
+}
+
+// handleLock takes the control lock. A client already holding it refreshes
+// it, possibly with a new timeout. An admin can take over the lock of
+// another client with force.
+func handleLock(c *websocket.Conn, payload json.RawMessage) {
+	var req struct {
+		TimeoutMs int64 `json:"timeout_ms"`
+		Force     bool  `json:"force"`
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			sendError(c, "parse_error", "Invalid lock payload", err.Error())
+			return
+		}
+	}
+	timeout := defaultLockTimeout
+	if req.TimeoutMs > 0 {
+		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
+	}
+	if timeout > maxLockTimeout {
+		sendError(c, "invalid_payload", "Lock timeout too long", fmt.Sprintf("maximum is %s", maxLockTimeout))
+		return
+	}
+	id := clientIdentity(c)
+
+	controlLockMu.Lock()
+	now := time.Now()
+	reason, acquired := "locked", now
+	if controlLock != nil {
+		switch {
+		case controlLock.conn == c:
+			reason, acquired = "refreshed", controlLock.AcquiredAt
+		case req.Force && id.Role.Allows(auth.Admin):
+			reason = "taken_over"
+		default:
+			owner := controlLock.Owner
+			controlLockMu.Unlock()
+			sendError(c, "locked", "Control is locked by another client", fmt.Sprintf("%s holds the control lock; an admin can take it over with force", owner))
+			return
+		}
+		controlLock.timer.Stop()
+	}
+	lock := &ControlLock{
+		ClientID:   clientID(c),
+		Owner:      id.Name,
+		Role:       id.Role,
+		AcquiredAt: acquired,
+		ExpiresAt:  now.Add(timeout),
+		TimeoutMs:  timeout.Milliseconds(),
+		conn:       c,
+		timeout:    timeout,
+	}
+	lock.timer = time.AfterFunc(timeout, func() { expireLock(lock) })
+	controlLock = lock
+	status := *lock
+	controlLockMu.Unlock()
+
+	broadcastLock(reason, &status)
+}
+
+// handleUnlock releases the control lock. Only its owner can release it,
+// or an admin with force.
+func handleUnlock(c *websocket.Conn, payload json.RawMessage) {
+	var req struct {
+		Force bool `json:"force"`
+	}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &req); err != nil {
+			sendError(c, "parse_error", "Invalid unlock payload", err.Error())
+			return
+		}
+	}
+
+	controlLockMu.Lock()
+	if controlLock == nil {
+		controlLockMu.Unlock()
+		sendError(c, "not_locked", "Control is not locked", "")
+		return
+	}
+	if controlLock.conn != c && (!req.Force || !clientIdentity(c).Role.Allows(auth.Admin)) {
+		owner := controlLock.Owner
+		controlLockMu.Unlock()
+		sendError(c, "locked", "Control is locked by another client", fmt.Sprintf("only %s or an admin with force can unlock it", owner))
+		return
+	}
+	controlLock.timer.Stop()
+	controlLock = nil
+	controlLockMu.Unlock()
+
+	broadcastLock("unlocked", nil)
+}
+
+func expireLock(lock *ControlLock) {
+	controlLockMu.Lock()
+	if controlLock != lock || time.Now().Before(lock.ExpiresAt) {
+		controlLockMu.Unlock()
+		return
+	}
+	controlLock = nil
+	controlLockMu.Unlock()
+
+	broadcastLock("expired", nil)
+}
+
+// releaseClientLock releases the lock of a client that disconnected.
+func releaseClientLock(c *websocket.Conn) {
+	controlLockMu.Lock()
+	if controlLock == nil || controlLock.conn != c {
+		controlLockMu.Unlock()
+		return
+	}
+	controlLock.timer.Stop()
+	controlLock = nil
+	controlLockMu.Unlock()
+
+	broadcastLock("disconnected", nil)
+}
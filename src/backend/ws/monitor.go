@@ -70,10 +70,13 @@ func broadcastDMXState() {
 	var activeShow string
 	var showStep int
 	var showLoop bool
+	var showTiming *ShowTiming
 	if currentShow != nil {
 		activeShow = currentShow.id
 		showStep = currentShow.currentStep
 		showLoop = currentShow.loop
+		t := currentShow.timing
+		showTiming = &t
 	}
 	showMu.Unlock()
 	state := DMXState{
@@ -82,6 +85,8 @@ func broadcastDMXState() {
 		ActiveShowID:   activeShow,
 		ShowStep:       showStep,
 		ShowLoop:       showLoop,
+		ShowTiming:     showTiming,
+		Playbacks:      currentPlaybacks(),
 		Timestamp:      time.Now().UnixMilli(),
 	}
 	select {
@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"elano.fr/src/backend/osc"
+	"elano.fr/src/backend/timecode"
+)
+
+func StartOSCListener(addr string) {
+	go func() {
+		log.Printf("Listening for OSC on %s", addr)
+		if err := osc.Listen(context.Background(), addr, handleOSCMessage); err != nil {
+			log.Printf("OSC input stopped: %v", err)
+		}
+	}()
+}
+
+func handleOSCMessage(m osc.Message) {
+	switch m.Address {
+	case "/luma/timecode":
+		fps := timecodeClock.FPS()
+		if f, ok := m.Float(1); ok && f > 0 {
+			fps = int(f)
+		}
+		if tc, ok := m.String(0); ok {
+			pos, err := timecode.Parse(tc, fps)
+			if err != nil {
+				log.Printf("Invalid OSC timecode %q: %v", tc, err)
+				return
+			}
+			timecodeClock.Set(pos, fps)
+		} else if secs, ok := m.Float(0); ok && secs >= 0 {
+			timecodeClock.Set(time.Duration(secs*float64(time.Second)), fps)
+		}
+	case "/luma/timecode/stop":
+		timecodeClock.Stop()
+	case "/luma/crossfade":
+		if pos, ok := m.Float(0); ok {
+			if err := ControlLocked(); err != nil {
+				log.Printf("OSC crossfade ignored: %v", err)
+				return
+			}
+			if err := SetCrossfade(pos); err != nil {
+				log.Printf("OSC crossfade: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"log"
+
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/palette"
+)
+
+// PaletteChanged pushes the new values of a palette to everything currently
+// playing a preset that references it: the active preset, loaded submasters
+// and the current step of a running show.
+func PaletteChanged(paletteID string) {
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+
+	// Every preset is resolved against the same snapshot of the project, so
+	// a preset deleted meanwhile is simply no longer found.
+	if projectStore == nil {
+		return
+	}
+	project := projectStore.Get()
+	if project == nil {
+		return
+	}
+	using := func(presetID string) *models.Preset {
+		for i := range project.Presets {
+			if pr := &project.Presets[i]; pr.ID == presetID && palette.Uses(*pr, paletteID) {
+				return pr
+			}
+		}
+		return nil
+	}
+
+	presetMu.Lock()
+	presetID := activePresetID
+	presetMu.Unlock()
+	if pr := using(presetID); ctrl != nil && pr != nil {
+		if err := ctrl.SetChannels(palette.Resolve(*pr, project)); err != nil {
+			log.Printf("Error refreshing preset %s: %v", presetID, err)
+		}
+	}
+
+	playbackMu.Lock()
+	for _, sub := range submasters {
+		pr := using(sub.PresetID)
+		if pr == nil {
+			continue
+		}
+		sub.Values = palette.Resolve(*pr, project)
+		if ctrl != nil {
+			if err := ctrl.SetSubmaster(sub.ID, sub.Values, sub.Level/100); err != nil {
+				log.Printf("Error refreshing submaster %s: %v", sub.ID, err)
+			}
+		}
+	}
+	playbackMu.Unlock()
+
+	showMu.Lock()
+	var showID string
+	var stepChannels map[int]byte
+	if currentShow != nil && currentShow.showData != nil && currentShow.currentStep < len(currentShow.showData.Steps) {
+		if pr := using(currentShow.showData.Steps[currentShow.currentStep].PresetID); pr != nil {
+			showID = currentShow.id
+			stepChannels = palette.Resolve(*pr, project)
+			currentShow.stepChannels = stepChannels
+		}
+	}
+	showMu.Unlock()
+	if ctrl != nil && stepChannels != nil {
+		if err := ctrl.SetChannels(scaleChannels(stepChannels, getPlaybackLevel(showID))); err != nil {
+			log.Printf("Error refreshing show %s: %v", showID, err)
+		}
+	}
+
+	broadcast <- Message{Type: "palette_changed", Payload: mustMarshal(map[string]interface{}{"palette_id": paletteID})}
+}
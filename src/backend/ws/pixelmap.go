@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"encoding/json"
+	"time"
+
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/pixelmap"
+	"github.com/gofiber/contrib/websocket"
+)
+
+var (
+	pixelEngine = pixelmap.NewEngine()
+	mediaDir    = ".data/media"
+)
+
+func SetMediaDir(dir string) {
+	mediaDir = dir
+}
+
+func handleStartPixelMap(c *websocket.Conn, payload json.RawMessage) {
+	var p struct {
+		PixelMapID string           `json:"pixel_map_id"`
+		Content    pixelmap.Content `json:"content"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid pixel map payload", err.Error())
+		return
+	}
+	if projectStore == nil {
+		sendError(c, "config_error", "Project store not initialized", "")
+		return
+	}
+	project := projectStore.Get()
+	if project == nil {
+		sendError(c, "config_error", "No project loaded", "")
+		return
+	}
+	var pm *models.PixelMap
+	for i := range project.PixelMaps {
+		if project.PixelMaps[i].ID == p.PixelMapID {
+			pm = &project.PixelMaps[i]
+			break
+		}
+	}
+	if pm == nil {
+		sendError(c, "pixel_map_not_found", "Pixel map not found", p.PixelMapID)
+		return
+	}
+	mapping, err := pixelmap.Resolve(*pm, project.Fixtures)
+	if err != nil {
+		sendError(c, "invalid_pixel_map", "Invalid pixel map", err.Error())
+		return
+	}
+	src, err := pixelmap.NewSource(p.Content, mediaDir)
+	if err != nil {
+		sendError(c, "invalid_content", "Invalid pixel map content", err.Error())
+		return
+	}
+	pixelEngine.Start(mapping, p.Content, src, time.Now())
+	broadcast <- Message{Type: "pixel_map_started", Payload: mustMarshal(pixelmap.ActiveMap{PixelMapID: pm.ID, Content: p.Content})}
+}
+
+func handleStopPixelMap(c *websocket.Conn, payload json.RawMessage) {
+	var p struct {
+		PixelMapID string `json:"pixel_map_id"`
+		All        bool   `json:"all"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid pixel map payload", err.Error())
+		return
+	}
+	if p.All {
+		stopAllPixelMaps()
+		return
+	}
+	if !pixelEngine.Stop(p.PixelMapID) {
+		sendError(c, "pixel_map_not_running", "Pixel map not running", p.PixelMapID)
+		return
+	}
+	broadcast <- Message{Type: "pixel_map_stopped", Payload: mustMarshal(map[string]interface{}{"pixel_map_id": p.PixelMapID})}
+}
+
+func stopAllPixelMaps() {
+	for _, id := range pixelEngine.StopAll() {
+		broadcast <- Message{Type: "pixel_map_stopped", Payload: mustMarshal(map[string]interface{}{"pixel_map_id": id})}
+	}
+}
@@ -0,0 +1,204 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"elano.fr/src/backend/dmx"
+	"elano.fr/src/backend/models"
+	"elano.fr/src/backend/palette"
+	"github.com/gofiber/contrib/websocket"
+)
+
+const playbackLevelFade = 50 * time.Millisecond
+
+func handleSetPlaybackLevel(c *websocket.Conn, payload json.RawMessage) {
+	var p PlaybackLevelPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid playback level payload", err.Error())
+		return
+	}
+	if p.PlaybackID == "" {
+		sendError(c, "invalid_payload", "Playback ID is required", "")
+		return
+	}
+	if p.Level < 0 || p.Level > 100 {
+		sendError(c, "invalid_payload", "Playback level must be 0-100", fmt.Sprintf("%g", p.Level))
+		return
+	}
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+
+	playbackMu.Lock()
+	sub, isSub := submasters[p.PlaybackID]
+	if isSub {
+		sub.Level = p.Level
+	} else {
+		playbackLevels[p.PlaybackID] = p.Level
+	}
+	playbackMu.Unlock()
+
+	if ctrl != nil {
+		if isSub {
+			if err := ctrl.SetSubmasterLevel(sub.ID, p.Level/100); err != nil {
+				sendError(c, "dmx_error", "Failed to set submaster level", err.Error())
+				return
+			}
+		} else {
+			showMu.Lock()
+			var channels map[int]byte
+			if currentShow != nil && currentShow.id == p.PlaybackID {
+				channels = currentShow.stepChannels
+			}
+			showMu.Unlock()
+			if channels != nil {
+				if err := ctrl.FadeChannels(scaleChannels(channels, p.Level), playbackLevelFade, dmx.FadeLinear); err != nil {
+					sendError(c, "dmx_error", "Failed to apply playback level", err.Error())
+					return
+				}
+			}
+		}
+	}
+	kind := "show"
+	if isSub {
+		kind = "submaster"
+	}
+	broadcast <- Message{Type: "playback_level", Payload: mustMarshal(map[string]interface{}{"playback_id": p.PlaybackID, "type": kind, "level": p.Level})}
+}
+
+func handleLoadSubmaster(c *websocket.Conn, payload json.RawMessage) {
+	var p SubmasterPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid submaster payload", err.Error())
+		return
+	}
+	if p.FaderID == "" || p.PresetID == "" {
+		sendError(c, "invalid_payload", "Fader ID and preset ID are required", "")
+		return
+	}
+	if p.Level < 0 || p.Level > 100 {
+		sendError(c, "invalid_payload", "Submaster level must be 0-100", fmt.Sprintf("%g", p.Level))
+		return
+	}
+	preset := findPreset(p.PresetID)
+	if preset == nil {
+		sendError(c, "preset_not_found", "Preset not found", p.PresetID)
+		return
+	}
+	sub := &SubmasterFader{ID: p.FaderID, PresetID: p.PresetID, Level: p.Level, Values: presetChannels(*preset)}
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	if ctrl != nil {
+		if err := ctrl.SetSubmaster(sub.ID, sub.Values, sub.Level/100); err != nil {
+			sendError(c, "dmx_error", "Failed to load submaster", err.Error())
+			return
+		}
+	}
+	playbackMu.Lock()
+	submasters[sub.ID] = sub
+	playbackMu.Unlock()
+	broadcast <- Message{Type: "submaster_loaded", Payload: mustMarshal(PlaybackState{ID: sub.ID, Type: "submaster", PresetID: sub.PresetID, Level: sub.Level})}
+}
+
+func handleUnloadSubmaster(c *websocket.Conn, payload json.RawMessage) {
+	var p SubmasterPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid submaster payload", err.Error())
+		return
+	}
+	playbackMu.Lock()
+	_, ok := submasters[p.FaderID]
+	delete(submasters, p.FaderID)
+	playbackMu.Unlock()
+	if !ok {
+		sendError(c, "submaster_not_found", "Submaster not found", p.FaderID)
+		return
+	}
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	if ctrl != nil {
+		ctrl.RemoveSubmaster(p.FaderID)
+	}
+	broadcast <- Message{Type: "submaster_unloaded", Payload: mustMarshal(map[string]interface{}{"fader_id": p.FaderID})}
+}
+
+func restoreSubmasters(ctrl *dmx.DMXController) {
+	playbackMu.RLock()
+	defer playbackMu.RUnlock()
+	for _, sub := range submasters {
+		if err := ctrl.SetSubmaster(sub.ID, sub.Values, sub.Level/100); err != nil {
+			log.Printf("Error restoring submaster %s: %v", sub.ID, err)
+		}
+	}
+}
+
+func getPlaybackLevel(showID string) float64 {
+	playbackMu.RLock()
+	defer playbackMu.RUnlock()
+	if level, ok := playbackLevels[showID]; ok {
+		return level
+	}
+	return 100
+}
+
+func currentPlaybacks() []PlaybackState {
+	var states []PlaybackState
+	showMu.Lock()
+	var showID string
+	if currentShow != nil {
+		showID = currentShow.id
+	}
+	showMu.Unlock()
+	if showID != "" {
+		states = append(states, PlaybackState{ID: showID, Type: "show", Level: getPlaybackLevel(showID)})
+	}
+	playbackMu.RLock()
+	subs := make([]PlaybackState, 0, len(submasters))
+	for _, sub := range submasters {
+		subs = append(subs, PlaybackState{ID: sub.ID, Type: "submaster", PresetID: sub.PresetID, Level: sub.Level})
+	}
+	playbackMu.RUnlock()
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	states = append(states, subs...)
+	return states
+}
+
+func scaleChannels(channels map[int]byte, level float64) map[int]byte {
+	scaled := make(map[int]byte, len(channels))
+	for ch, v := range channels {
+		scaled[ch] = byte(float64(v) * level / 100)
+	}
+	return scaled
+}
+
+func findPreset(id string) *models.Preset {
+	if projectStore == nil {
+		return nil
+	}
+	project := projectStore.Get()
+	if project == nil {
+		return nil
+	}
+	for _, p := range project.Presets {
+		if p.ID == id {
+			return &p
+		}
+	}
+	return nil
+}
+
+// presetChannels resolves the palette references of p against the current
+// project, so palette edits reach every playback of the preset.
+func presetChannels(p models.Preset) map[int]byte {
+	var project *models.Project
+	if projectStore != nil {
+		project = projectStore.Get()
+	}
+	return palette.Resolve(p, project)
+}
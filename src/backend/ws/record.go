@@ -0,0 +1,206 @@
+package ws
+
+import (
+	"encoding/json"
+	"sort"
+
+	"elano.fr/src/backend/models"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	RecordReplace = "replace"
+	RecordMerge   = "merge"
+	RecordRemove  = "remove"
+
+	RecordAll     = "all"
+	RecordNonZero = "non_zero"
+	RecordChanged = "changed"
+)
+
+func handleRecordPreset(c *websocket.Conn, payload json.RawMessage) {
+	var p RecordPresetPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid record payload", err.Error())
+		return
+	}
+	if _, err := RecordPreset(p); err != nil {
+		sendActionError(c, err)
+	}
+}
+
+// RecordPreset snapshots the live channel values into a new preset, or into
+// the existing preset p.PresetID according to p.Mode, and saves the project.
+func RecordPreset(p RecordPresetPayload) (*models.Preset, error) {
+	if p.Mode == "" {
+		p.Mode = RecordReplace
+	}
+	if p.Filter == "" {
+		p.Filter = RecordAll
+	}
+	switch p.Mode {
+	case RecordReplace, RecordMerge, RecordRemove:
+	default:
+		return nil, newActionError("invalid_payload", "Unknown record mode", p.Mode)
+	}
+	switch p.Filter {
+	case RecordAll, RecordNonZero, RecordChanged:
+	default:
+		return nil, newActionError("invalid_payload", "Unknown record filter", p.Filter)
+	}
+
+	if projectStore == nil {
+		return nil, newActionError("config_error", "Project store not initialized", "")
+	}
+	project := projectStore.Get()
+	if project == nil {
+		return nil, newActionError("config_error", "No project loaded", "")
+	}
+
+	dmxCtrlMu.RLock()
+	ctrl := dmxCtrl
+	dmxCtrlMu.RUnlock()
+	if ctrl == nil {
+		return nil, newActionError("dmx_error", "DMX controller not initialized", "")
+	}
+	live, err := ctrl.GetAllChannels()
+	if err != nil {
+		return nil, newActionError("dmx_error", "Failed to read channels", err.Error())
+	}
+
+	index := -1
+	var preset models.Preset
+	if p.PresetID != "" {
+		for i, pr := range project.Presets {
+			if pr.ID == p.PresetID {
+				index = i
+				preset = pr
+				break
+			}
+		}
+		if index < 0 {
+			return nil, newActionError("preset_not_found", "Preset not found", p.PresetID)
+		}
+	} else {
+		if p.Name == "" {
+			return nil, newActionError("invalid_payload", "Preset name is required", "")
+		}
+		if p.Mode == RecordRemove {
+			return nil, newActionError("invalid_payload", "Remove mode requires an existing preset", "")
+		}
+		preset = models.Preset{ID: uuid.NewString()}
+	}
+	if p.Name != "" {
+		preset.Name = p.Name
+	}
+
+	addresses, err := recordAddresses(project, p.FixtureIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := make(map[int]byte)
+	if index >= 0 {
+		previous = presetChannels(preset)
+	}
+	snapshot := make(map[int]byte)
+	for _, addr := range addresses {
+		v := live[addr-1]
+		switch p.Filter {
+		case RecordNonZero:
+			if v == 0 {
+				continue
+			}
+		case RecordChanged:
+			if old, ok := previous[addr]; (ok && old == v) || (!ok && v == 0) {
+				continue
+			}
+		}
+		snapshot[addr] = v
+	}
+
+	values := make(map[int]byte)
+	if p.Mode != RecordReplace {
+		for _, ch := range preset.Channels {
+			values[ch.DMXAddress] = ch.Value
+		}
+	}
+	for addr, v := range snapshot {
+		if p.Mode == RecordRemove {
+			delete(values, addr)
+		} else {
+			values[addr] = v
+		}
+	}
+	if len(values) == 0 && len(preset.Palettes) == 0 {
+		return nil, newActionError("invalid_payload", "Recorded preset has no channels", "")
+	}
+
+	preset.Channels = make([]models.ChannelValue, 0, len(values))
+	for addr, v := range values {
+		preset.Channels = append(preset.Channels, models.ChannelValue{DMXAddress: addr, Value: v})
+	}
+	sort.Slice(preset.Channels, func(i, j int) bool {
+		return preset.Channels[i].DMXAddress < preset.Channels[j].DMXAddress
+	})
+
+	if index >= 0 {
+		project.Presets[index] = preset
+	} else {
+		project.Presets = append(project.Presets, preset)
+	}
+	if err := projectStore.Save(project); err != nil {
+		return nil, newActionError("storage_error", "Failed to save preset", err.Error())
+	}
+
+	broadcast <- Message{Type: "preset_recorded", Payload: mustMarshal(map[string]interface{}{"preset": preset, "mode": p.Mode, "created": index < 0})}
+	return &preset, nil
+}
+
+// recordAddresses returns the DMX addresses of the given fixtures, fine
+// channels included, or every address when no fixture is selected.
+func recordAddresses(project *models.Project, fixtureIDs []string) ([]int, error) {
+	if len(fixtureIDs) == 0 {
+		addresses := make([]int, 512)
+		for i := range addresses {
+			addresses[i] = i + 1
+		}
+		return addresses, nil
+	}
+	seen := make(map[int]bool)
+	var addresses []int
+	for _, id := range fixtureIDs {
+		found := false
+		for _, f := range project.Fixtures {
+			if f.ID != id {
+				continue
+			}
+			found = true
+			for _, ch := range f.Channels {
+				// The fine byte of a 16-bit channel is recorded with its
+				// coarse byte, or the value would be off when played back.
+				for _, addr := range []int{ch.ChannelAddress, ch.FineChannelAddress} {
+					if addr >= 1 && addr <= 512 && !seen[addr] {
+						seen[addr] = true
+						addresses = append(addresses, addr)
+					}
+				}
+			}
+			break
+		}
+		if !found {
+			return nil, newActionError("fixture_not_found", "Fixture not found", id)
+		}
+	}
+	return addresses, nil
+}
+
+// ActionErrorType returns the error type of an error returned by one of the
+// exported actions, or "internal_error".
+func ActionErrorType(err error) string {
+	if ae, ok := err.(*actionError); ok {
+		return ae.errType
+	}
+	return "internal_error"
+}
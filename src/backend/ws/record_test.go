@@ -0,0 +1,50 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+
+	"elano.fr/src/backend/models"
+)
+
+func TestRecordAddresses(t *testing.T) {
+	project := &models.Project{Fixtures: []models.Fixture{
+		{ID: "head", Channels: []models.FixtureChannel{
+			{Name: "pan", ChannelAddress: 10, FineChannelAddress: 11},
+			{Name: "tilt", ChannelAddress: 12, FineChannelAddress: 13},
+			{Name: "dimmer", ChannelAddress: 14},
+		}},
+		{ID: "par", Channels: []models.FixtureChannel{
+			{Name: "dimmer", ChannelAddress: 14},
+			{Name: "red", ChannelAddress: 20},
+		}},
+	}}
+
+	tests := []struct {
+		name     string
+		fixtures []string
+		want     []int
+	}{
+		{"fine channels", []string{"head"}, []int{10, 11, 12, 13, 14}},
+		{"shared addresses once", []string{"head", "par"}, []int{10, 11, 12, 13, 14, 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := recordAddresses(project, tt.fixtures)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recordAddresses = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	all, err := recordAddresses(project, nil)
+	if err != nil || len(all) != 512 {
+		t.Errorf("recordAddresses without fixtures = %d addresses, %v; want 512", len(all), err)
+	}
+	if _, err := recordAddresses(project, []string{"missing"}); ActionErrorType(err) != "fixture_not_found" {
+		t.Errorf("recordAddresses of an unknown fixture = %v, want fixture_not_found", err)
+	}
+}
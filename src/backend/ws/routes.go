@@ -3,9 +3,13 @@ package ws
 import (
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 func SetupWebSocketRoutes(app *fiber.App) {
-	app.Get("/ws/control", websocket.New(handleWebSocket))
+	app.Get("/ws/control", func(c *fiber.Ctx) error {
+		c.Locals("id", uuid.NewString())
+		return c.Next()
+	}, websocket.New(handleWebSocket))
 	go handleBroadcast()
 }
@@ -9,24 +9,33 @@ import (
 	"elano.fr/src/backend/dmx"
 )
 
-func runShowSequence(ctx context.Context, ctrl *dmx.DMXController, show ShowPayload, showID string) {
+func runShowSequence(ctx context.Context, ctrl *dmx.DMXController, clock Clock, show ShowPayload, showID string) {
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("Recovered from panic in runShowSequence: %v", r)
 		}
-		showMu.Lock()
-		if currentShow != nil && currentShow.id == showID {
-			currentShow = nil
-		}
-		showMu.Unlock()
-		broadcast <- Message{Type: "show_stopped", Payload: mustMarshal(map[string]interface{}{"show_id": showID})}
+		endShow(showID)
 	}()
 
+	scheduleSteps(ctx, clock, show, func(i int, step ShowStep, lateness time.Duration) bool {
+		return applyShowStep(ctx, ctrl, showID, i, len(show.Steps), step, step.FadeMs, lateness)
+	})
+}
+
+// scheduleSteps plays the steps of show with apply until the show ends, ctx is
+// cancelled or apply returns false. apply receives how late the step started.
+func scheduleSteps(ctx context.Context, clock Clock, show ShowPayload, apply func(i int, step ShowStep, lateness time.Duration) bool) {
+	// Every step is scheduled against an absolute deadline measured from the
+	// show start, so time spent fading and broadcasting does not accumulate
+	// across steps or loops.
+	start := clock.Now()
+	var offset time.Duration
 	for {
 		if err := ctx.Err(); err != nil {
 			return
 		}
 
+		loopStart := offset
 		for i, step := range show.Steps {
 			select {
 			case <-ctx.Done():
@@ -34,61 +43,101 @@ func runShowSequence(ctx context.Context, ctrl *dmx.DMXController, show ShowPayl
 			default:
 			}
 
-			showMu.Lock()
-			if currentShow == nil || currentShow.id != showID {
-				showMu.Unlock()
+			lateness := clock.Now().Sub(start.Add(offset))
+			if !apply(i, step, lateness) {
 				return
 			}
-			currentShow.currentStep = i
-			showMu.Unlock()
 
-			if err := ctrl.Blackout(); err != nil {
-				log.Printf("Error during blackout: %v", err)
+			offset += time.Duration(step.Duration) * time.Millisecond
+			if !waitUntil(ctx, clock, start.Add(offset)) {
+				return
 			}
+		}
 
-			channels := make(map[int]byte)
-			for addrStr, val := range step.Preset {
-				addr, err := strconv.Atoi(addrStr)
-				if err != nil || addr < 1 || addr > 512 || val < 0 || val > 255 {
-					continue
-				}
-				channels[addr] = byte(val)
-			}
+		if !show.Loop {
+			break
+		}
 
-			if step.FadeMs > 0 {
-				if err := ctrl.FadeChannels(channels, time.Duration(step.FadeMs)*time.Millisecond, dmx.FadeLinear); err != nil {
-					performManualFade(ctx, ctrl, channels, step.FadeMs)
-				}
-			} else {
-				if err := ctrl.SetChannels(channels); err != nil {
-					log.Printf("Error setting channels in show step %d: %v", i, err)
-				}
+		if offset == loopStart {
+			// A show without durations would otherwise spin.
+			offset += minLoopInterval
+			if !waitUntil(ctx, clock, start.Add(offset)) {
+				return
 			}
+		}
+	}
+}
 
-			broadcast <- Message{
-				Type:    "show_step",
-				Payload: mustMarshal(map[string]interface{}{"step": i, "total": len(show.Steps), "show_id": showID}),
-			}
+func waitUntil(ctx context.Context, clock Clock, deadline time.Time) bool {
+	d := deadline.Sub(clock.Now())
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-clock.After(d):
+		return true
+	}
+}
 
-			if step.Duration > 0 {
-				select {
-				case <-ctx.Done():
-					return
-				case <-time.After(time.Duration(step.Duration) * time.Millisecond):
-				}
+func endShow(showID string) {
+	showMu.Lock()
+	if currentShow != nil && currentShow.id == showID {
+		currentShow = nil
+	}
+	showMu.Unlock()
+	broadcast <- Message{Type: "show_stopped", Payload: mustMarshal(map[string]interface{}{"show_id": showID})}
+}
+
+func applyShowStep(ctx context.Context, ctrl *dmx.DMXController, showID string, i, total int, step ShowStep, fadeMs int, lateness time.Duration) bool {
+	stepChannels := make(map[int]byte)
+	if p := findPreset(step.PresetID); p != nil {
+		// Resolve palettes when the step plays rather than when the show
+		// started, so palette edits reach a running show.
+		stepChannels = presetChannels(*p)
+	} else {
+		for addrStr, val := range step.Preset {
+			addr, err := strconv.Atoi(addrStr)
+			if err != nil || addr < 1 || addr > 512 || val < 0 || val > 255 {
+				continue
 			}
+			stepChannels[addr] = byte(val)
 		}
+	}
 
-		if !show.Loop {
-			break
-		}
+	showMu.Lock()
+	if currentShow == nil || currentShow.id != showID {
+		showMu.Unlock()
+		return false
+	}
+	currentShow.currentStep = i
+	currentShow.stepChannels = stepChannels
+	currentShow.timing.record(lateness)
+	timing := currentShow.timing
+	showMu.Unlock()
 
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(100 * time.Millisecond):
+	if err := ctrl.Blackout(); err != nil {
+		log.Printf("Error during blackout: %v", err)
+	}
+
+	channels := scaleChannels(stepChannels, getPlaybackLevel(showID))
+
+	if fadeMs > 0 {
+		if err := ctrl.FadeChannels(channels, time.Duration(fadeMs)*time.Millisecond, dmx.FadeLinear); err != nil {
+			performManualFade(ctx, ctrl, channels, fadeMs)
+		}
+	} else {
+		if err := ctrl.SetChannels(channels); err != nil {
+			log.Printf("Error setting channels in show step %d: %v", i, err)
 		}
 	}
+
+	broadcast <- Message{
+		Type:    "show_step",
+		Payload: mustMarshal(map[string]interface{}{"step": i, "total": total, "show_id": showID, "timing": timing}),
+	}
+	return true
 }
 
 func performManualFade(ctx context.Context, ctrl *dmx.DMXController, targetChannels map[int]byte, fadeMs int) {
@@ -0,0 +1,156 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when told to: After advances it to the deadline at
+// once, and steps advance it to simulate the time they take.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+type playedStep struct {
+	index    int
+	at       time.Duration
+	lateness time.Duration
+}
+
+// playShow runs show on a fake clock, each step taking work[i] to apply, and
+// returns the steps played, stopping after limit steps.
+func playShow(t *testing.T, show ShowPayload, work []time.Duration, limit int) ([]playedStep, time.Duration) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)}
+	start := clock.now
+	var played []playedStep
+	scheduleSteps(context.Background(), clock, show, func(i int, step ShowStep, lateness time.Duration) bool {
+		played = append(played, playedStep{index: i, at: clock.now.Sub(start), lateness: lateness})
+		clock.now = clock.now.Add(work[i])
+		return len(played) < limit
+	})
+	return played, clock.now.Sub(start)
+}
+
+func steps(durations ...int) []ShowStep {
+	s := make([]ShowStep, len(durations))
+	for i, d := range durations {
+		s[i].Duration = d
+	}
+	return s
+}
+
+func TestScheduleStepsDeadlines(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		show    ShowPayload
+		work    []time.Duration
+		limit   int
+		want    []playedStep
+		wantEnd time.Duration
+	}{
+		{
+			name: "slow steps do not drift",
+			show: ShowPayload{Steps: steps(100, 200, 300)},
+			work: []time.Duration{30 * ms, 30 * ms, 30 * ms},
+			want: []playedStep{
+				{0, 0, 0},
+				{1, 100 * ms, 0},
+				{2, 300 * ms, 0},
+			},
+			wantEnd: 600 * ms,
+		},
+		{
+			name: "an overrun delays only the next step",
+			show: ShowPayload{Steps: steps(100, 100, 100)},
+			work: []time.Duration{150 * ms, 0, 0},
+			want: []playedStep{
+				{0, 0, 0},
+				{1, 150 * ms, 50 * ms},
+				{2, 200 * ms, 0},
+			},
+			wantEnd: 300 * ms,
+		},
+		{
+			name:  "loops keep counting from the show start",
+			show:  ShowPayload{Steps: steps(100, 50), Loop: true},
+			work:  []time.Duration{10 * ms, 10 * ms},
+			limit: 5,
+			want: []playedStep{
+				{0, 0, 0},
+				{1, 100 * ms, 0},
+				{0, 150 * ms, 0},
+				{1, 250 * ms, 0},
+				{0, 300 * ms, 0},
+			},
+		},
+		{
+			name:  "loops without durations wait between passes",
+			show:  ShowPayload{Steps: steps(0, 0), Loop: true},
+			work:  []time.Duration{0, 0},
+			limit: 4,
+			want: []playedStep{
+				{0, 0, 0},
+				{1, 0, 0},
+				{0, minLoopInterval, 0},
+				{1, minLoopInterval, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := tt.limit
+			if limit == 0 {
+				limit = len(tt.want) + 1
+			}
+			played, end := playShow(t, tt.show, tt.work, limit)
+			if len(played) != len(tt.want) {
+				t.Fatalf("played %d steps %v, want %d", len(played), played, len(tt.want))
+			}
+			for i := range played {
+				if played[i] != tt.want[i] {
+					t.Errorf("step %d = %+v, want %+v", i, played[i], tt.want[i])
+				}
+			}
+			if tt.wantEnd != 0 && end != tt.wantEnd {
+				t.Errorf("show ended at %v, want %v", end, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestScheduleStepsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := &fakeClock{now: time.Now()}
+	calls := 0
+	scheduleSteps(ctx, clock, ShowPayload{Steps: steps(0, 0, 0), Loop: true}, func(int, ShowStep, time.Duration) bool {
+		calls++
+		cancel()
+		return true
+	})
+	if calls != 1 {
+		t.Errorf("played %d steps after cancel, want 1", calls)
+	}
+}
+
+func TestShowTimingRecord(t *testing.T) {
+	var timing ShowTiming
+	for _, ms := range []time.Duration{50, -80, 10} {
+		timing.record(ms * time.Millisecond)
+	}
+	want := ShowTiming{Steps: 3, LastErrorMs: 10, MaxErrorMs: -80, MeanErrorMs: -20.0 / 3}
+	if timing != want {
+		t.Errorf("timing = %+v, want %+v", timing, want)
+	}
+}
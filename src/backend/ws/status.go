@@ -14,10 +14,13 @@ func handleGetStatus(c *websocket.Conn) {
 	var showID string
 	var showStep int
 	var showLoop bool
+	var timing *ShowTiming
 	if currentShow != nil {
 		showID = currentShow.id
 		showStep = currentShow.currentStep
 		showLoop = currentShow.loop
+		t := currentShow.timing
+		timing = &t
 	}
 	showMu.Unlock()
 
@@ -35,9 +38,17 @@ func handleGetStatus(c *websocket.Conn) {
 		"active_show_id":    showID,
 		"show_step":         showStep,
 		"show_loop":         showLoop,
+		"show_timing":       timing,
 		"active_preset_id":  activePreset,
 		"monitoring":        isMonitoring,
+		"playbacks":         currentPlaybacks(),
+		"timecode":          timecodeStatus(),
+		"effects":           effectEngine.List(),
+		"pixel_maps":        pixelEngine.Active(),
+		"crossfade":         crossfadeStatus(),
 		"connected_clients": getClientCount(),
+		"client_id":         clientID(c),
+		"lock":              lockStatus(),
 	}
 
 	writeJSON(c, Message{
@@ -0,0 +1,172 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"elano.fr/src/backend/dmx"
+	"elano.fr/src/backend/timecode"
+	"github.com/gofiber/contrib/websocket"
+)
+
+const (
+	timecodeTimeout   = time.Second
+	timecodeTick      = 10 * time.Millisecond
+	timecodeJumpLimit = 500 * time.Millisecond
+)
+
+var timecodeClock = timecode.NewClock(timecodeTimeout)
+
+func StartMTCListener(device string) {
+	go func() {
+		for {
+			log.Printf("Listening for MIDI timecode on %s", device)
+			if err := timecode.ListenMTC(device, timecodeClock); err != nil {
+				log.Printf("MIDI timecode input stopped: %v", err)
+			}
+			time.Sleep(5 * time.Second)
+		}
+	}()
+}
+
+func isTimecodeShow(show ShowPayload) bool {
+	for _, step := range show.Steps {
+		if step.Timecode != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func timecodeAnchors(show ShowPayload) ([]time.Duration, error) {
+	anchors := make([]time.Duration, len(show.Steps))
+	for i, step := range show.Steps {
+		if step.Timecode == "" {
+			return nil, fmt.Errorf("step %d has no timecode", i)
+		}
+		pos, err := timecode.Parse(step.Timecode, show.TimecodeFPS)
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		if i > 0 && pos <= anchors[i-1] {
+			return nil, fmt.Errorf("step %d timecode %s is not after the previous step", i, step.Timecode)
+		}
+		anchors[i] = pos
+	}
+	return anchors, nil
+}
+
+func runTimecodeShow(ctx context.Context, ctrl *dmx.DMXController, show ShowPayload, anchors []time.Duration, showID string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic in runTimecodeShow: %v", r)
+		}
+		endShow(showID)
+	}()
+
+	ticker := time.NewTicker(timecodeTick)
+	defer ticker.Stop()
+
+	current := -1
+	started := false
+	var lastPos time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pos, running := timecodeClock.Position()
+		if !running {
+			if started {
+				return
+			}
+			continue
+		}
+		jumped := !started || pos < lastPos || pos-lastPos > timecodeJumpLimit
+		started = true
+		lastPos = pos
+
+		idx := sort.Search(len(anchors), func(i int) bool { return anchors[i] > pos }) - 1
+		if idx == current {
+			continue
+		}
+		if idx != current+1 {
+			jumped = true
+		}
+		current = idx
+
+		if idx < 0 {
+			showMu.Lock()
+			if currentShow == nil || currentShow.id != showID {
+				showMu.Unlock()
+				return
+			}
+			currentShow.currentStep = 0
+			currentShow.stepChannels = nil
+			showMu.Unlock()
+			if err := ctrl.Blackout(); err != nil {
+				log.Printf("Error during blackout: %v", err)
+			}
+			continue
+		}
+
+		// Chasing into the middle of a step snaps to its look; a step reached in
+		// normal playback fades for whatever part of its fade time remains.
+		fadeMs := 0
+		var lateness time.Duration
+		if !jumped {
+			lateness = pos - anchors[idx]
+			fadeMs = show.Steps[idx].FadeMs - int(lateness/time.Millisecond)
+		}
+		if !applyShowStep(ctx, ctrl, showID, idx, len(show.Steps), show.Steps[idx], max(fadeMs, 0), lateness) {
+			return
+		}
+	}
+}
+
+func handleTimecode(c *websocket.Conn, payload json.RawMessage) {
+	var p TimecodePayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		sendError(c, "invalid_payload", "Invalid timecode payload", err.Error())
+		return
+	}
+	fps := p.FPS
+	if fps <= 0 {
+		fps = timecodeClock.FPS()
+	}
+	switch {
+	case p.Timecode != "":
+		pos, err := timecode.Parse(p.Timecode, fps)
+		if err != nil {
+			sendError(c, "invalid_payload", "Invalid timecode", err.Error())
+			return
+		}
+		timecodeClock.Set(pos, fps)
+	case p.PositionMs != nil && *p.PositionMs >= 0:
+		timecodeClock.Set(time.Duration(*p.PositionMs)*time.Millisecond, fps)
+	default:
+		sendError(c, "invalid_payload", "Timecode or position_ms is required", "")
+	}
+}
+
+func handleTimecodeStop(c *websocket.Conn) {
+	timecodeClock.Stop()
+	writeJSON(c, Message{Type: "timecode_stopped", Payload: json.RawMessage("{}")})
+}
+
+func timecodeStatus() map[string]interface{} {
+	pos, running := timecodeClock.Position()
+	fps := timecodeClock.FPS()
+	return map[string]interface{}{
+		"timecode":    timecode.Format(pos, fps),
+		"position_ms": pos.Milliseconds(),
+		"fps":         fps,
+		"running":     running,
+	}
+}
@@ -19,23 +19,104 @@ type ChannelUpdatePayload struct {
 
 type PresetPayload map[string]int
 
+type ApplyOptions struct {
+	Mode   string `json:"mode,omitempty"`
+	FadeMs int    `json:"fade_ms,omitempty"`
+	Curve  string `json:"curve,omitempty"`
+}
+
 type ShowStep struct {
+	PresetID string        `json:"preset_id,omitempty"`
 	Preset   PresetPayload `json:"preset"`
 	Duration int           `json:"duration"`
 	FadeMs   int           `json:"fade_ms"`
+	Timecode string        `json:"timecode,omitempty"`
 }
 
 type ShowPayload struct {
-	Steps []ShowStep `json:"steps"`
-	Loop  bool       `json:"loop,omitempty"`
+	Steps       []ShowStep `json:"steps"`
+	Loop        bool       `json:"loop,omitempty"`
+	TimecodeFPS int        `json:"timecode_fps,omitempty"`
+}
+
+type TimecodePayload struct {
+	Timecode   string `json:"timecode,omitempty"`
+	PositionMs *int64 `json:"position_ms,omitempty"`
+	FPS        int    `json:"fps,omitempty"`
 }
 
 type ShowController struct {
-	cancel      context.CancelFunc
-	id          string
-	currentStep int
-	showData    *models.Show
-	loop        bool
+	cancel       context.CancelFunc
+	id           string
+	currentStep  int
+	showData     *models.Show
+	loop         bool
+	stepChannels map[int]byte
+	timing       ShowTiming
+}
+
+type ShowTiming struct {
+	Steps       int     `json:"steps"`
+	LastErrorMs float64 `json:"last_error_ms"`
+	MaxErrorMs  float64 `json:"max_error_ms"`
+	MeanErrorMs float64 `json:"mean_error_ms"`
+}
+
+type PlaybackLevelPayload struct {
+	PlaybackID string  `json:"playback_id"`
+	Level      float64 `json:"level"`
+}
+
+type SubmasterPayload struct {
+	FaderID  string  `json:"fader_id"`
+	PresetID string  `json:"preset_id"`
+	Level    float64 `json:"level"`
+}
+
+type SubmasterFader struct {
+	ID       string
+	PresetID string
+	Level    float64
+	Values   map[int]byte
+}
+
+type CrossfadePayload struct {
+	PresetA  string   `json:"preset_a"`
+	PresetB  string   `json:"preset_b"`
+	Position *float64 `json:"position"`
+}
+
+type CrossfadeState struct {
+	PresetA  string  `json:"preset_a"`
+	PresetB  string  `json:"preset_b"`
+	Position float64 `json:"position"`
+}
+
+type RecordPresetPayload struct {
+	PresetID   string   `json:"preset_id,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	FixtureIDs []string `json:"fixture_ids,omitempty"`
+	Filter     string   `json:"filter,omitempty"`
+	Mode       string   `json:"mode,omitempty"`
+}
+
+type EffectPayload struct {
+	ID         string   `json:"id"`
+	Waveform   string   `json:"waveform"`
+	Speed      float64  `json:"speed"`
+	Size       float64  `json:"size"`
+	Offset     float64  `json:"offset"`
+	Phase      float64  `json:"phase"`
+	Channels   []int    `json:"channels,omitempty"`
+	FixtureIDs []string `json:"fixture_ids,omitempty"`
+	Channel    string   `json:"channel,omitempty"`
+}
+
+type PlaybackState struct {
+	ID       string  `json:"id"`
+	Type     string  `json:"type"`
+	PresetID string  `json:"preset_id,omitempty"`
+	Level    float64 `json:"level"`
 }
 
 type ErrorResponse struct {
@@ -49,10 +130,12 @@ type ChannelState struct {
 }
 
 type DMXState struct {
-	Channels       []ChannelState `json:"channels"`
-	ActivePresetID string         `json:"active_preset_id,omitempty"`
-	ActiveShowID   string         `json:"active_show_id,omitempty"`
-	ShowStep       int            `json:"show_step,omitempty"`
-	ShowLoop       bool           `json:"show_loop,omitempty"`
-	Timestamp      int64          `json:"timestamp"`
+	Channels       []ChannelState  `json:"channels"`
+	ActivePresetID string          `json:"active_preset_id,omitempty"`
+	ActiveShowID   string          `json:"active_show_id,omitempty"`
+	ShowStep       int             `json:"show_step,omitempty"`
+	ShowLoop       bool            `json:"show_loop,omitempty"`
+	ShowTiming     *ShowTiming     `json:"show_timing,omitempty"`
+	Playbacks      []PlaybackState `json:"playbacks,omitempty"`
+	Timestamp      int64           `json:"timestamp"`
 }
@@ -24,9 +24,9 @@ func sendError(c *websocket.Conn, errType, message, details string) {
 	}
 }
 func getConnMutex(c *websocket.Conn) *sync.Mutex {
-	if m, ok := clients.Load(c); ok {
-		if mu, ok := m.(*sync.Mutex); ok {
-			return mu
+	if v, ok := clients.Load(c); ok {
+		if cl, ok := v.(*client); ok {
+			return &cl.mu
 		}
 	}
 	return nil
@@ -0,0 +1,21 @@
+const TOKEN_KEY = "luma.token";
+
+// API token of this console. It can be handed over once in the URL
+// (?token=...), after which it is kept in local storage and removed from the
+// address bar.
+export function getToken(): string | null {
+  const params = new URLSearchParams(window.location.search);
+  const fromUrl = params.get("token");
+  if (fromUrl) {
+    localStorage.setItem(TOKEN_KEY, fromUrl);
+    params.delete("token");
+    const query = params.toString();
+    window.history.replaceState(
+      null,
+      "",
+      window.location.pathname + (query ? `?${query}` : "") + window.location.hash
+    );
+    return fromUrl;
+  }
+  return localStorage.getItem(TOKEN_KEY);
+}
@@ -1,4 +1,9 @@
 import ky from "ky";
+import { getToken } from "./auth";
+
+// Project revision last seen from the API. Mutations send it back as If-Match
+// so the server can reject edits made against a stale project.
+let projectRevision: string | null = null;
 
 const kyClient = ky.create({
   prefixUrl: "/api",
@@ -8,6 +13,27 @@ const kyClient = ky.create({
     methods: ["get"],
     statusCodes: [408, 413, 429, 500, 502, 503, 504],
   },
+  hooks: {
+    beforeRequest: [
+      (request) => {
+        const token = getToken();
+        if (token) {
+          request.headers.set("Authorization", `Bearer ${token}`);
+        }
+        if (request.method !== "GET" && projectRevision) {
+          request.headers.set("If-Match", projectRevision);
+        }
+      },
+    ],
+    afterResponse: [
+      (_request, _options, response) => {
+        const etag = response.headers.get("ETag");
+        if (etag) {
+          projectRevision = etag;
+        }
+      },
+    ],
+  },
 });
 
 export default kyClient;
@@ -9,6 +9,7 @@ import { QueryClientProvider } from "@tanstack/react-query";
 import { ReactQueryDevtools } from "@tanstack/react-query-devtools";
 import { StrictMode } from "react";
 import { createRoot } from "react-dom/client";
+import { getToken } from "./integration/auth";
 import { DmxWebSocketProvider } from "./providers/ws-provider";
 
 function makeWebSocketUrl(path = "/ws/control") {
@@ -18,7 +19,10 @@ function makeWebSocketUrl(path = "/ws/control") {
   // only include “:port” if there actually is one
   let portPart = port ? `${port}` : "";
   if (port === "5173") portPart = "3000";
-  return `${protocol}://${hostname}:${portPart}${path}`;
+  // browsers cannot set headers on a WebSocket, so the token goes in the URL
+  const token = getToken();
+  const query = token ? `?token=${encodeURIComponent(token)}` : "";
+  return `${protocol}://${hostname}:${portPart}${path}${query}`;
 }
 
 //"ws://localhost:3000/ws/control"
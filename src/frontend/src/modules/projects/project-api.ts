@@ -6,14 +6,6 @@ async function getProject() {
   return kyClient.get("projects").json<Project>();
 }
 
-async function createProject(project: Omit<Project, "id">) {
-  return kyClient
-    .post("projects", {
-      json: project,
-    })
-    .json<Project>();
-}
-
 async function updateProject(project: Project) {
   return kyClient
     .put("projects", {
@@ -22,10 +14,6 @@ async function updateProject(project: Project) {
     .json<Project>();
 }
 
-async function deleteProject() {
-  return kyClient.delete("projects").json<void>();
-}
-
 export const projectQueryOptions = {
   current: () => ({
     queryKey: ["project"] as const,
@@ -38,19 +26,6 @@ export function useProject() {
   return useQuery(projectQueryOptions.current());
 }
 
-export function useCreateProject() {
-  const queryClient = useQueryClient();
-  return useMutation({
-    mutationFn: createProject,
-    onSuccess: (data) => {
-      queryClient.setQueryData(["project"], data);
-      queryClient.invalidateQueries({ queryKey: ["fixtures"] });
-      queryClient.invalidateQueries({ queryKey: ["presets"] });
-      queryClient.invalidateQueries({ queryKey: ["shows"] });
-    },
-  });
-}
-
 export function useUpdateProject() {
   const queryClient = useQueryClient();
   return useMutation({
@@ -61,19 +36,6 @@ export function useUpdateProject() {
   });
 }
 
-export function useDeleteProject() {
-  const queryClient = useQueryClient();
-  return useMutation({
-    mutationFn: deleteProject,
-    onSuccess: () => {
-      queryClient.removeQueries({ queryKey: ["project"] });
-      queryClient.removeQueries({ queryKey: ["fixtures"] });
-      queryClient.removeQueries({ queryKey: ["presets"] });
-      queryClient.removeQueries({ queryKey: ["shows"] });
-    },
-  });
-}
-
 export function useProjectName() {
   const { data: project } = useProject();
   return project?.name || "Untitled Project";
@@ -9,6 +9,7 @@ import {
   useState,
   type ReactNode,
 } from "react";
+import { useQueryClient } from "@tanstack/react-query";
 import useWebSocket, { ReadyState } from "react-use-websocket";
 
 export const OutgoingMessageType = {
@@ -30,6 +31,7 @@ export const IncomingMessageType = {
   DMX_STATE: "dmx_state",
   DMX_UPDATE: "dmx_update",
   PROJECT_CONFIG: "project_config",
+  PROJECT_CHANGED: "project_changed",
   MONITORING_STARTED: "monitoring_started",
   MONITORING_STOPPED: "monitoring_stopped",
   ERROR: "error",
@@ -69,6 +71,12 @@ export interface ProjectConfigPayload {
   presets: Preset[];
   shows: Show[];
 }
+export interface ProjectChangedPayload {
+  entity: string;
+  id: string;
+  op: "create" | "update" | "delete";
+  data: unknown;
+}
 export interface ErrorPayload {
   error: string;
   details?: string;
@@ -93,6 +101,7 @@ function useInternalDmxWebSocket(url: string, reconnectOnClose = true) {
   const [projectConfig, setProjectConfig] =
     useState<ProjectConfigPayload | null>(null);
   const [error, setError] = useState<ErrorPayload | null>(null);
+  const queryClient = useQueryClient();
 
   useEffect(() => {
     if (!lastJsonMessage) return;
@@ -113,13 +122,20 @@ function useInternalDmxWebSocket(url: string, reconnectOnClose = true) {
       case IncomingMessageType.PROJECT_CONFIG:
         setProjectConfig(msg.payload as ProjectConfigPayload);
         break;
+      case IncomingMessageType.PROJECT_CHANGED: {
+        const change = msg.payload as ProjectChangedPayload;
+        const queryKey =
+          change.entity === "project" ? ["project"] : [`${change.entity}s`];
+        queryClient.invalidateQueries({ queryKey });
+        break;
+      }
       case IncomingMessageType.ERROR:
         setError(msg.payload as ErrorPayload);
         break;
       default:
         break;
     }
-  }, [lastJsonMessage]);
+  }, [lastJsonMessage, queryClient]);
 
   const applyPreset = useCallback(
     (presetId: string) => {